@@ -3,6 +3,7 @@ package cron
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 // Value defines the value type
@@ -15,11 +16,26 @@ func (v Value) IsZero() bool {
 
 // Cron defines the cron structure
 type Cron struct {
+	// Second is only set for crons parsed with ParseSeconds
+	Second        Value `json:"second,omitempty"`
 	Minute        Value `json:"minute"`
 	Hour          Value `json:"hour"`
 	DayOfTheMonth Value `json:"dayOfTheMonth"`
 	Month         Value `json:"month"`
 	DayOfTheWeek  Value `json:"dayOfTheWeek"`
+
+	// Year is only set for crons parsed with ParseYear
+	Year Value `json:"year,omitempty"`
+
+	// Macro is the @-macro the cron was given as, e.g. `@daily`, in which
+	// case the fields hold its expansion, if any
+	Macro Value `json:"macro,omitempty"`
+
+	// Every is the interval of an `@every` macro
+	Every time.Duration `json:"every,omitempty"`
+
+	// Options is the syntax accepted for the fields
+	Options ParseOption `json:"options,omitempty"`
 }
 
 // Format returns a formatted line that can be added to a crontab
@@ -41,12 +57,51 @@ func (c *Cron) IsValid() bool {
 		return false
 	}
 
-	_, err := Parse(c.Format("root", "cmd"))
+	_, err := NewParser(c.Options).Parse(c.Format("root", "cmd"))
 	return err == nil
 }
 
+// IsVixie returns true if the cron is valid and can be read by Vixie cron,
+// i.e. it uses none of the syntax enabled by a ParseOption
+func (c *Cron) IsVixie() bool {
+	if c.IsZero() || !c.Second.IsZero() || !c.Year.IsZero() || c.Every != 0 {
+		return false
+	}
+
+	if _, err := Parse(c.Format("root", "cmd")); err != nil {
+		return false
+	}
+	if !c.Macro.IsZero() {
+		return true
+	}
+
+	fields, err := c.Fields()
+	if err != nil {
+		return false
+	}
+
+	// NOTE: Vixie cron only accepts three-letter names, e.g. `Mon`
+	for _, e := range fields {
+		if e == nil {
+			continue
+		}
+		for _, t := range e.Terms {
+			for _, a := range []Atom{t.Start, t.End} {
+				if len(a.Text) > 3 && !isDigits(a.Text) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
 // IsZero returns true if cron is empry
 func (c *Cron) IsZero() bool {
+	if !c.Macro.IsZero() {
+		return false
+	}
+
 	return c.Minute.IsZero() ||
 		c.Hour.IsZero() ||
 		c.DayOfTheMonth.IsZero() ||
@@ -59,40 +114,201 @@ func (c *Cron) String() string {
 		return ""
 	}
 
-	return fmt.Sprintf("%v %v %v %v %v", c.Minute, c.Hour, c.DayOfTheMonth,
+	if !c.Macro.IsZero() {
+		return string(c.Macro)
+	}
+
+	s := fmt.Sprintf("%v %v %v %v %v", c.Minute, c.Hour, c.DayOfTheMonth,
 		c.Month, c.DayOfTheWeek)
+	if !c.Second.IsZero() {
+		s = string(c.Second) + " " + s
+	}
+	if !c.Year.IsZero() {
+		s += " " + string(c.Year)
+	}
+	return s
+}
+
+// Fields returns the parsed fields of the cron, indexed by Field.
+// The seconds and year are nil if not set
+func (c *Cron) Fields() ([]*Expr, error) {
+	if c.Macro == "@reboot" || c.Every != 0 {
+		return nil, fmt.Errorf("cron `%s` has no fields", c.Macro)
+	}
+
+	// NOTE: fields are parsed in the order they are written, so that the
+	// first invalid one is reported
+	values := []struct {
+		field Field
+		value Value
+	}{
+		{FieldSecond, c.Second},
+		{FieldMinute, c.Minute},
+		{FieldHour, c.Hour},
+		{FieldDayOfTheMonth, c.DayOfTheMonth},
+		{FieldMonth, c.Month},
+		{FieldDayOfTheWeek, c.DayOfTheWeek},
+		{FieldYear, c.Year},
+	}
+
+	p := NewParser(c.Options)
+	list := make([]*Expr, len(values))
+	for _, v := range values {
+		if v.value.IsZero() && (v.field == FieldSecond || v.field == FieldYear) {
+			continue
+		}
+
+		e, err := p.ParseField(v.field, string(v.value))
+		if err != nil {
+			return nil, err
+		}
+		list[v.field] = e
+	}
+
+	return list, nil
+}
+
+// ParseOption defines syntax accepted by a Parser beyond Vixie cron's
+type ParseOption int
+
+// Parse options
+const (
+	// ParseSeconds expects a seconds field before the minute
+	ParseSeconds ParseOption = 1 << iota
+
+	// ParseYear accepts a year field after the day of the week. Since it
+	// is optional, a command that is a valid year field is taken as one
+	ParseYear
+
+	// ParseEvery accepts the `@every <duration>` macro, e.g. `@every 5m`
+	ParseEvery
+
+	// ParseLast accepts `L` for the last day of the month, and after a day
+	// of the week for its last one in the month, e.g. `FRIL`
+	ParseLast
+
+	// ParseWeekday accepts `W` after a day of the month, for the nearest
+	// weekday in the same month, e.g. `15W`. Along with ParseLast, `LW`
+	// stands for the last weekday of the month
+	ParseWeekday
+
+	// ParseNth accepts `#` in the day of the week, for its nth occurrence
+	// in the month, e.g. `FRI#3`
+	ParseNth
+
+	// ParseNoSpecificValue accepts `?` as the whole day of the month or
+	// of the week, which then matches any day, as `*` does
+	ParseNoSpecificValue
+
+	// ParseQuartz accepts Quartz expressions, with cron's numbering of
+	// the days of the week
+	ParseQuartz = ParseSeconds | ParseYear | ParseLast | ParseWeekday | ParseNth | ParseNoSpecificValue
+)
+
+// macros maps the standard @-macros to their fields
+var macros = map[string][5]Value{
+	"@yearly":   {"0", "0", "1", "1", "*"},
+	"@annually": {"0", "0", "1", "1", "*"},
+	"@monthly":  {"0", "0", "1", "*", "*"},
+	"@weekly":   {"0", "0", "*", "*", "0"},
+	"@daily":    {"0", "0", "*", "*", "*"},
+	"@midnight": {"0", "0", "*", "*", "*"},
+	"@hourly":   {"0", "*", "*", "*", "*"},
+}
+
+// Parser parses cron expressions
+type Parser struct {
+	Options ParseOption
+}
+
+// NewParser returns a parser accepting the given options
+func NewParser(opts ParseOption) *Parser {
+	return &Parser{Options: opts}
 }
 
 // Parse parses the given string and returns its Cron representation.
-// Parsing only considers the first 5 elements, ignoring the rest.
+// Fields are separated by blanks, and parsing only considers the schedule,
+// ignoring the rest. The standard @-macros, including `@reboot`, are
+// accepted. Errors are of type *ParseError when a field is invalid
 func Parse(s string) (*Cron, error) {
-	list := strings.Split(s, " ")
+	return NewParser(0).Parse(s)
+}
+
+// Parse parses the given string as the package's Parse does, accepting
+// the parser's options
+func (p *Parser) Parse(s string) (*Cron, error) {
+	list := strings.Fields(s)
+	if len(list) > 0 && strings.HasPrefix(list[0], "@") {
+		return p.parseMacro(list)
+	}
+
+	c := &Cron{Options: p.Options}
+	if p.Options&ParseSeconds != 0 {
+		if len(list) < 6 {
+			return nil, fmt.Errorf("a cron expression with seconds must have at least 6 entries")
+		}
+		c.Second = Value(list[0])
+		list = list[1:]
+	}
+
 	if len(list) < 5 {
 		return nil, fmt.Errorf("a cron expression must have at least 5 entries (and a command)")
 	}
 
-	if !minuteIsValid(list[0]) {
-		return nil, fmt.Errorf("invalid expression for minutes")
-	}
-	if !hourIsValid(list[1]) {
-		return nil, fmt.Errorf("invalid expression for hour")
+	c.Minute = Value(list[0])
+	c.Hour = Value(list[1])
+	c.DayOfTheMonth = Value(list[2])
+	c.Month = Value(list[3])
+	c.DayOfTheWeek = Value(list[4])
+
+	if p.Options&ParseYear != 0 && len(list) > 5 {
+		if _, err := p.ParseField(FieldYear, list[5]); err == nil {
+			c.Year = Value(list[5])
+		}
 	}
-	if !dayOfTheMonthIsValid(list[2]) {
-		return nil, fmt.Errorf("invalid expression for day-of-the-month")
+
+	if _, err := c.Fields(); err != nil {
+		return nil, err
 	}
-	if !monthIsValid(list[3]) {
-		return nil, fmt.Errorf("invalid expression for month")
+
+	return c, nil
+}
+
+func (p *Parser) parseMacro(list []string) (*Cron, error) {
+	c := &Cron{Macro: Value(list[0]), Options: p.Options}
+
+	switch list[0] {
+	case "@reboot":
+		return c, nil
+	case "@every":
+		if p.Options&ParseEvery == 0 {
+			return nil, fmt.Errorf("the `@every` macro is not enabled")
+		}
+		if len(list) < 2 {
+			return nil, fmt.Errorf("the `@every` macro requires a duration")
+		}
+
+		d, err := time.ParseDuration(list[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid `@every` duration: %w", err)
+		}
+		if d < time.Second {
+			return nil, fmt.Errorf("the `@every` duration must be at least 1s, got %v", d)
+		}
+
+		c.Macro = Value(list[0] + " " + list[1])
+		c.Every = d
+		return c, nil
 	}
-	if !dayOfTheWeekIsValid(list[4]) {
-		return nil, fmt.Errorf("invalid expression for day-of-the-week")
+
+	fields, ok := macros[list[0]]
+	if !ok {
+		return nil, fmt.Errorf("unknown cron macro `%s`", list[0])
 	}
 
-	return &Cron{
-			Minute:        Value(list[0]),
-			Hour:          Value(list[1]),
-			DayOfTheMonth: Value(list[2]),
-			Month:         Value(list[3]),
-			DayOfTheWeek:  Value(list[4]),
-		},
-		nil
+	c.Minute, c.Hour, c.DayOfTheMonth, c.Month, c.DayOfTheWeek = fields[0], fields[1], fields[2], fields[3], fields[4]
+	if p.Options&ParseSeconds != 0 {
+		c.Second = "0"
+	}
+	return c, nil
 }
@@ -0,0 +1,259 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		name    string
+		expr    string
+		wantErr bool
+		field   Field
+		pos     int
+	}{
+		{name: "stars", expr: "* * * * * cmd"},
+		{name: "steps", expr: "*/7 5/15 1-10/3 */2 * cmd"},
+		{name: "lists", expr: "0,15-30/5,45 8-18 1,15 1-6,9 MON-FRI cmd"},
+		{name: "case-insensitive names", expr: "0 0 * jan-Mar,DEC sun,Sat cmd"},
+		{name: "Sunday as 7", expr: "0 0 * * 5-7 cmd"},
+		{name: "leading zeroes", expr: "05 09 01 01 0 cmd"},
+		{name: "too few fields", expr: "* * * *", wantErr: true},
+		{name: "hour out of range", expr: "0 24 * * * cmd", wantErr: true, field: FieldHour, pos: 1},
+		{name: "day zero", expr: "0 0 0 * * cmd", wantErr: true, field: FieldDayOfTheMonth, pos: 1},
+		{name: "week day 8", expr: "0 0 * * 1,8 cmd", wantErr: true, field: FieldDayOfTheWeek, pos: 3},
+		{name: "reversed range", expr: "0 0 * * FRI-MON cmd", wantErr: true, field: FieldDayOfTheWeek, pos: 1},
+		{name: "name in minutes", expr: "mon * * * * cmd", wantErr: true, field: FieldMinute, pos: 1},
+		{name: "unknown name", expr: "0 0 * foo * cmd", wantErr: true, field: FieldMonth, pos: 1},
+		{name: "zero step", expr: "*/0 * * * * cmd", wantErr: true, field: FieldMinute, pos: 3},
+		{name: "empty item", expr: "1,,2 * * * * cmd", wantErr: true, field: FieldMinute, pos: 3},
+		{name: "missing range end", expr: "0 1- * * * cmd", wantErr: true, field: FieldHour, pos: 3},
+		{name: "star in range", expr: "*-5 * * * * cmd", wantErr: true, field: FieldMinute, pos: 1},
+		{name: "first invalid field", expr: "mon 24 * * * cmd", wantErr: true, field: FieldMinute, pos: 1},
+		{name: "last not enabled", expr: "0 0 L * * cmd", wantErr: true, field: FieldDayOfTheMonth, pos: 1},
+		{name: "no specific value not enabled", expr: "0 0 ? * MON cmd", wantErr: true, field: FieldDayOfTheMonth, pos: 1},
+		{name: "every not enabled", expr: "@every 5m cmd", wantErr: true},
+		{name: "unknown macro", expr: "@sometimes cmd", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Parse(tc.expr)
+			if tc.wantErr {
+				assert.Error(t, err)
+
+				var perr *ParseError
+				if errors.As(err, &perr) {
+					assert.Equal(t, tc.field, perr.Field)
+					assert.Equal(t, tc.pos, perr.Pos)
+				} else {
+					assert.Equal(t, 0, tc.pos)
+				}
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expr, c.Format("", "cmd"))
+
+			fields, err := c.Fields()
+			assert.NoError(t, err)
+			for i, f := range fields[:FieldSecond] {
+				assert.Equal(t, Field(i), f.Field)
+			}
+			assert.Equal(t, c.String(), fields[0].String()+" "+fields[1].String()+" "+
+				fields[2].String()+" "+fields[3].String()+" "+fields[4].String())
+		})
+	}
+}
+
+func TestParseField(t *testing.T) {
+	e, err := ParseField(FieldDayOfTheWeek, "1-5/2,Sun,7")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(e.Terms))
+
+	assert.Equal(t, TermRange, e.Terms[0].Kind)
+	assert.Equal(t, 1, e.Terms[0].Start.Value)
+	assert.Equal(t, 5, e.Terms[0].End.Value)
+	assert.Equal(t, 2, e.Terms[0].Step.Value)
+	assert.Equal(t, true, e.Terms[0].HasStep())
+
+	assert.Equal(t, TermValue, e.Terms[1].Kind)
+	assert.Equal(t, 0, e.Terms[1].Start.Value)
+	assert.Equal(t, "Sun", e.Terms[1].Start.Text)
+	assert.Equal(t, 7, e.Terms[1].Pos)
+
+	assert.Equal(t, 7, e.Terms[2].Start.Value)
+	assert.Equal(t, "1-5/2,Sun,7", e.String())
+}
+
+func TestParseBlanks(t *testing.T) {
+	c, err := Parse("0\t9  * *\tMON-FRI   cmd --flag")
+	assert.NoError(t, err)
+	assert.Equal(t, "0 9 * * MON-FRI", c.String())
+}
+
+func TestParseMacros(t *testing.T) {
+	testCases := []struct {
+		expr string
+		want string
+	}{
+		{"@yearly", "0 0 1 1 *"},
+		{"@annually", "0 0 1 1 *"},
+		{"@monthly", "0 0 1 * *"},
+		{"@weekly", "0 0 * * 0"},
+		{"@daily", "0 0 * * *"},
+		{"@midnight", "0 0 * * *"},
+		{"@hourly", "0 * * * *"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			c, err := Parse(tc.expr + " cmd")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expr, c.String())
+			assert.Equal(t, tc.expr+" root cmd", c.Format("root", "cmd"))
+			assert.Equal(t, true, c.IsVixie())
+
+			expanded, err := Parse(tc.want + " cmd")
+			assert.NoError(t, err)
+			from := time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)
+			assert.Equal(t, expanded.NextN(from, 3), c.NextN(from, 3))
+		})
+	}
+
+	t.Run("@reboot", func(t *testing.T) {
+		c, err := Parse("@reboot cmd")
+		assert.NoError(t, err)
+		assert.Equal(t, "@reboot", c.String())
+		assert.Equal(t, true, c.IsValid())
+		assert.Equal(t, true, c.IsVixie())
+		assert.Equal(t, true, c.Next(time.Now()).IsZero())
+
+		_, err = c.Fields()
+		assert.Error(t, err)
+	})
+
+	t.Run("@every", func(t *testing.T) {
+		p := NewParser(ParseEvery)
+		c, err := p.Parse("@every 1h30m cmd")
+		assert.NoError(t, err)
+		assert.Equal(t, "@every 1h30m", c.String())
+		assert.Equal(t, 90*time.Minute, c.Every)
+		assert.Equal(t, true, c.IsValid())
+		assert.Equal(t, false, c.IsVixie())
+
+		from := time.Date(2024, 1, 1, 0, 0, 0, 500, time.UTC)
+		next := c.Next(from)
+		assert.Equal(t, time.Date(2024, 1, 1, 1, 30, 0, 0, time.UTC), next)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), c.Prev(next))
+
+		_, err = p.Parse("@every 10ms cmd")
+		assert.Error(t, err)
+		_, err = p.Parse("@every")
+		assert.Error(t, err)
+	})
+}
+
+func TestParser(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    ParseOption
+		expr    string
+		want    string
+		wantErr bool
+	}{
+		{name: "seconds", opts: ParseSeconds, expr: "*/10 0 9 * * * cmd", want: "*/10 0 9 * * *"},
+		{name: "missing seconds", opts: ParseSeconds, expr: "0 9 * * *", wantErr: true},
+		{name: "second out of range", opts: ParseSeconds, expr: "60 0 9 * * * cmd", wantErr: true},
+		{name: "seconds macro", opts: ParseSeconds, expr: "@daily cmd", want: "@daily"},
+		{name: "year", opts: ParseYear, expr: "0 9 * * * 2025-2030 cmd", want: "0 9 * * * 2025-2030"},
+		{name: "no year", opts: ParseYear, expr: "0 9 * * * cmd", want: "0 9 * * *"},
+		{name: "year out of range is the command", opts: ParseYear, expr: "0 9 * * * 1900", want: "0 9 * * *"},
+		{name: "quartz", opts: ParseQuartz, expr: "0 15 10 ? * FRI#3 2025", want: "0 15 10 ? * FRI#3 2025"},
+		{name: "last day", opts: ParseLast, expr: "0 0 L * *", want: "0 0 L * *"},
+		{name: "last friday", opts: ParseLast, expr: "0 0 * * 5L,1", want: "0 0 * * 5L,1"},
+		{name: "last without day", opts: ParseLast, expr: "0 0 * * L", wantErr: true},
+		{name: "nearest weekday", opts: ParseWeekday, expr: "0 0 15W,1 * *", want: "0 0 15W,1 * *"},
+		{name: "weekday out of range", opts: ParseWeekday, expr: "0 0 32W * *", wantErr: true},
+		{name: "last weekday needs both", opts: ParseWeekday, expr: "0 0 LW * *", wantErr: true},
+		{name: "last weekday", opts: ParseLast | ParseWeekday, expr: "0 0 LW * *", want: "0 0 LW * *"},
+		{name: "nth", opts: ParseNth, expr: "0 0 * * MON#1", want: "0 0 * * MON#1"},
+		{name: "nth out of range", opts: ParseNth, expr: "0 0 * * MON#6", wantErr: true},
+		{name: "nth in day of the month", opts: ParseNth, expr: "0 0 1#2 * *", wantErr: true},
+		{name: "step after extension", opts: ParseLast, expr: "0 0 L/2 * *", wantErr: true},
+		{name: "no specific value", opts: ParseNoSpecificValue, expr: "0 0 * * ?", want: "0 0 * * ?"},
+		{name: "no specific value in list", opts: ParseNoSpecificValue, expr: "0 0 ?,1 * *", wantErr: true},
+		{name: "no specific value in hours", opts: ParseNoSpecificValue, expr: "0 ? * * *", wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewParser(tc.opts).Parse(tc.expr)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.want, c.String())
+			assert.Equal(t, true, c.IsValid())
+
+			// NOTE: the options travel with the cron
+			fields, err := c.Fields()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.opts&ParseSeconds != 0, fields[FieldSecond] != nil)
+		})
+	}
+}
+
+func TestIsVixie(t *testing.T) {
+	c, err := NewParser(ParseQuartz).Parse("0 0 0 L * ?")
+	assert.NoError(t, err)
+	assert.Equal(t, true, c.IsValid())
+	assert.Equal(t, false, c.IsVixie())
+
+	err = NewInstall("").Add(c, "root", "cmd")
+	assert.Error(t, err)
+
+	c, err = NewParser(ParseQuartz).Parse("0 0 12 * * MON")
+	assert.NoError(t, err)
+	assert.Equal(t, false, c.IsVixie())
+
+	c, err = NewParser(ParseQuartz).Parse("@weekly")
+	assert.NoError(t, err)
+	assert.Equal(t, false, c.IsVixie())
+
+	c, err = Parse("0 12 * * MON cmd")
+	assert.NoError(t, err)
+	assert.Equal(t, true, c.IsVixie())
+
+	c, err = Parse("0 12 * january-Mar Monday cmd")
+	assert.NoError(t, err)
+	assert.Equal(t, true, c.IsValid())
+	assert.Equal(t, false, c.IsVixie())
+
+	err = NewInstall("").Add(c, "root", "cmd")
+	assert.Error(t, err)
+
+	c, err = Parse("0 12 * Jan-mar mon,0005 cmd")
+	assert.NoError(t, err)
+	assert.Equal(t, true, c.IsVixie())
+
+	err = NewInstall("").Add(c, "root", "cmd")
+	assert.NoError(t, err)
+}
+
+func TestParseFirstInvalidField(t *testing.T) {
+	p := NewParser(ParseSeconds | ParseYear)
+	for range 50 {
+		_, err := p.Parse("60 mon 24 * * * cmd")
+
+		var perr *ParseError
+		assert.Equal(t, true, errors.As(err, &perr))
+		assert.Equal(t, FieldSecond, perr.Field)
+	}
+}
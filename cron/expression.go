@@ -1,50 +1,65 @@
 package cron
 
 import (
-	"slices"
+	"fmt"
 	"strconv"
 	"strings"
 )
 
-type cronExpr int
+// Field defines a field of a cron expression
+type Field int
 
+// Cron fields, in the order they appear in an expression
 const (
-	cronExprUnknown cronExpr = iota
-	cronExprStar
-	cronExprStarNth
-	cronExprStarNthMinute
-	cronExprStarNthHour
-	cronExprStarNthMonth
-	cronExprDigit
-	cronExprMinute
-	cronExprHour
-	cronExprDay
-	cronExprMonth
-	cronExprWeek
-	cronExprMonthName
-	cronExprWeekName
-	cronExprMinuteRange
-	cronExprHourRange
-	cronExprDayRange
-	cronExprMonthRange
-	cronExprWeekRange
-	cronExprMontNamehRange
-	cronExprWeekNameRange
-	cronExprMinuteRangeNth
-	cronExprHourRangeNth
-	cronExprMonthRangeNth
-	cronExprMonthNameRangeNth
+	FieldMinute Field = iota
+	FieldHour
+	FieldDayOfTheMonth
+	FieldMonth
+	FieldDayOfTheWeek
+
+	// FieldSecond and FieldYear are only used with ParseSeconds and
+	// ParseYear, before the minute and after the day of the week
+	FieldSecond
+	FieldYear
 )
 
-type cronHint int
+var fieldNames = map[Field]string{
+	FieldMinute:        "minute",
+	FieldHour:          "hour",
+	FieldDayOfTheMonth: "day-of-the-month",
+	FieldMonth:         "month",
+	FieldDayOfTheWeek:  "day-of-the-week",
+	FieldSecond:        "second",
+	FieldYear:          "year",
+}
 
-const (
-	cronHintMinute cronHint = iota
-	cronHintHour
-	cronHintDay
-	cronHintMonth
-	cronHintWeek
-)
+func (f Field) String() string {
+	if name, ok := fieldNames[f]; ok {
+		return name
+	}
+	return "field(" + strconv.Itoa(int(f)) + ")"
+}
+
+// Min returns the lowest value allowed for the field
+func (f Field) Min() int {
+	return fieldBounds[f][0]
+}
+
+// Max returns the highest value allowed for the field.
+// For the day of the week it is 7, an alias of Sunday (0)
+func (f Field) Max() int {
+	return fieldBounds[f][1]
+}
+
+var fieldBounds = map[Field][2]int{
+	FieldMinute:        {0, 59},
+	FieldHour:          {0, 23},
+	FieldDayOfTheMonth: {1, 31},
+	FieldMonth:         {1, 12},
+	FieldDayOfTheWeek:  {0, 7},
+	FieldSecond:        {0, 59},
+	FieldYear:          {1970, 2099},
+}
 
 var (
 	namesOfDays = map[string]int{
@@ -91,194 +106,357 @@ var (
 	}
 )
 
-func classifyCronExpr(e string, h cronHint) cronExpr {
-	if e == "*" {
-		return cronExprStar
+// names returns the names accepted by the field, if any
+func (f Field) names() map[string]int {
+	switch f {
+	case FieldMonth:
+		return namesOfMonths
+	case FieldDayOfTheWeek:
+		return namesOfDays
 	}
+	return nil
+}
 
-	digitRangeReturn := func(lr []string, ret cronExpr) cronExpr {
-		lv, err := strconv.ParseInt(lr[0], 10, 64)
-		if err != nil {
-			return cronExprUnknown
-		}
+// ParseError defines an error in a cron expression
+type ParseError struct {
+	Field Field
 
-		rv, err := strconv.ParseInt(lr[1], 10, 64)
-		if err != nil {
-			return cronExprUnknown
-		}
+	// Text is the text of the offending field
+	Text string
 
-		if lv >= rv {
-			return cronExprUnknown
-		}
+	// Pos is the 1-based position of the error in Text
+	Pos int
 
-		return ret
-	}
+	Msg string
+}
 
-	if strings.Contains(e, "/") {
-		list := strings.Split(e, "/")
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("invalid %s field %q at position %d: %s", e.Field, e.Text, e.Pos, e.Msg)
+}
 
-		if len(list) != 2 {
-			return cronExprUnknown
-		}
+// TermKind defines the kind of a term
+type TermKind int
 
-		above := classifyCronExpr(list[0], h)
-		below := classifyCronExpr(list[1], h)
+// Term kinds
+const (
+	// TermAny is `*`
+	TermAny TermKind = iota
 
-		if above == cronExprStar && below == cronExprDigit {
-			return cronExprStarNth
-		}
+	// TermValue is a single value, e.g. `5` or `MON`
+	TermValue
 
-		if h == cronHintMinute && slices.Contains([]cronExpr{cronExprStar, cronExprMinuteRange}, above) &&
-			slices.Contains([]cronExpr{cronExprMinute, cronExprDigit}, below) &&
-			slices.Contains([]string{"2", "3", "4", "5", "6", "10", "12", "15", "20", "30"}, list[1]) {
-			return cronExprMinuteRangeNth
-		}
+	// TermRange is an inclusive range, e.g. `1-5` or `MON-FRI`
+	TermRange
 
-		if h == cronHintHour && slices.Contains([]cronExpr{cronExprStar, cronExprHourRange}, above) &&
-			slices.Contains([]cronExpr{cronExprHour, cronExprDigit}, below) &&
-			slices.Contains([]string{"2", "3", "4", "6", "8", "12"}, list[1]) {
-			return cronExprHourRangeNth
-		}
+	// TermLast is `L` in the day of the month, for its last day, or a day
+	// of the week followed by `L`, e.g. `5L`, for its last one in the month
+	TermLast
 
-		if h == cronHintMonth && slices.Contains([]cronExpr{cronExprStar, cronExprMonthRange, cronExprMontNamehRange}, above) &&
-			slices.Contains([]cronExpr{cronExprMonth, cronExprDigit}, below) &&
-			slices.Contains([]string{"2", "3", "4", "6"}, list[1]) {
-			return cronExprMonthRangeNth
-		}
+	// TermWeekday is a day of the month followed by `W`, e.g. `15W`, for
+	// the nearest weekday in the same month
+	TermWeekday
 
-		return cronExprUnknown
-	}
+	// TermLastWeekday is `LW`, for the last weekday of the month
+	TermLastWeekday
 
-	if strings.Contains(e, "-") {
-		list := strings.Split(e, "-")
+	// TermNth is a day of the week followed by `#` and its occurrence in
+	// the month, which is held in End, e.g. `FRI#3`
+	TermNth
 
-		if len(list) != 2 {
-			return cronExprUnknown
-		}
+	// TermNoValue is `?`, which matches any day of the month or week
+	TermNoValue
+)
 
-		left := classifyCronExpr(list[0], h)
-		right := classifyCronExpr(list[1], h)
+// Atom defines a number or name in a term
+type Atom struct {
+	Value int `json:"value"`
 
-		if left == cronExprMinute && right == cronExprMinute {
-			return digitRangeReturn(list, cronExprMinuteRange)
-		}
+	// Text is the atom as written, e.g. `05` or `Mon`
+	Text string `json:"text"`
+}
 
-		if left == cronExprHour && right == cronExprHour {
-			return digitRangeReturn(list, cronExprHourRange)
-		}
+func (a Atom) String() string {
+	return a.Text
+}
 
-		if left == cronExprDay && right == cronExprDay {
-			return digitRangeReturn(list, cronExprDayRange)
-		}
+// Term defines an element of a field's list, with an optional step.
+// A value with a step, e.g. `5/15`, stands for the range from the
+// value to the field's maximum
+type Term struct {
+	Kind  TermKind `json:"kind"`
+	Start Atom     `json:"start"`
+	End   Atom     `json:"end"`
 
-		if left == cronExprMonth && right == cronExprMonth {
-			return digitRangeReturn(list, cronExprMonthRange)
-		}
+	// Step is set if its Text is not empty
+	Step Atom `json:"step"`
 
-		if left == cronExprWeek && right == cronExprWeek {
-			return digitRangeReturn(list, cronExprWeekRange)
-		}
+	// Pos is the 1-based position of the term in the field's text
+	Pos int `json:"pos"`
+}
 
-		if left == cronExprMonthName && right == cronExprMonthName {
-			if namesOfMonths[list[0]] >= namesOfMonths[list[1]] {
-				return cronExprUnknown
-			}
+// HasStep returns true if the term has a step
+func (t Term) HasStep() bool {
+	return t.Step.Text != ""
+}
 
-			return cronExprMontNamehRange
-		}
+func (t Term) String() string {
+	var s string
+	switch t.Kind {
+	case TermAny:
+		s = "*"
+	case TermValue:
+		s = t.Start.Text
+	case TermRange:
+		s = t.Start.Text + "-" + t.End.Text
+	case TermLast:
+		s = t.Start.Text + "L"
+	case TermWeekday:
+		s = t.Start.Text + "W"
+	case TermLastWeekday:
+		s = "LW"
+	case TermNth:
+		s = t.Start.Text + "#" + t.End.Text
+	case TermNoValue:
+		s = "?"
+	}
 
-		if left == cronExprWeekName && right == cronExprWeekName {
-			if namesOfDays[list[0]] >= namesOfDays[list[1]] {
-				return cronExprUnknown
-			}
+	if t.HasStep() {
+		s += "/" + t.Step.Text
+	}
+	return s
+}
+
+// Expr defines a parsed cron field, i.e. a comma-separated list of terms
+type Expr struct {
+	Field Field  `json:"field"`
+	Terms []Term `json:"terms"`
+}
+
+// String returns the expression as written
+func (e *Expr) String() string {
+	list := make([]string, len(e.Terms))
+	for i, t := range e.Terms {
+		list[i] = t.String()
+	}
+	return strings.Join(list, ",")
+}
 
-			return cronExprWeekNameRange
+// ParseField parses the text of the given cron field
+func ParseField(f Field, s string) (*Expr, error) {
+	return NewParser(0).ParseField(f, s)
+}
+
+// ParseField parses the text of the given cron field, accepting the
+// parser's options
+func (p *Parser) ParseField(f Field, s string) (*Expr, error) {
+	if _, ok := fieldBounds[f]; !ok {
+		return nil, fmt.Errorf("unknown cron field %d", int(f))
+	}
+
+	fp := &fieldParser{field: f, text: s, opts: p.Options}
+
+	e := &Expr{Field: f}
+	pos := 0
+	for _, item := range strings.Split(s, ",") {
+		t, err := fp.term(item, pos)
+		if err != nil {
+			return nil, err
 		}
+		e.Terms = append(e.Terms, t)
+		pos += len(item) + 1
+	}
+
+	return e, nil
+}
 
-		return cronExprUnknown
+// fieldParser parses the terms of a field
+type fieldParser struct {
+	field Field
+	text  string
+	opts  ParseOption
+}
+
+func (p *fieldParser) errorf(pos int, format string, args ...any) error {
+	return &ParseError{Field: p.field, Text: p.text, Pos: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+// term parses a list item starting at the given offset of the field's text
+func (p *fieldParser) term(s string, pos int) (t Term, err error) {
+	t.Pos = pos + 1
+	if s == "" {
+		err = p.errorf(pos, "empty list item")
+		return
 	}
 
-	if h == cronHintWeek {
-		if _, ok := namesOfDays[strings.ToLower(e)]; ok {
-			return cronExprWeekName
+	body, step, hasStep := strings.Cut(s, "/")
+	if hasStep {
+		if t.Step, err = p.step(step, pos+len(body)+1); err != nil {
+			return
 		}
 	}
 
-	if h == cronHintMonth {
-		if _, ok := namesOfMonths[strings.ToLower(e)]; ok {
-			return cronExprMonthName
+	if ok, err := p.extension(&t, body, pos); ok || err != nil {
+		if err == nil && hasStep {
+			err = p.errorf(pos+len(body), "step not allowed after %q", body)
 		}
+		return t, err
 	}
 
-	if v, err := strconv.ParseInt(e, 10, 64); err == nil {
-		switch h {
-		case cronHintMinute:
-			if v >= 0 && v <= 59 {
-				return cronExprMinute
-			}
-		case cronHintHour:
-			if v >= 0 && v <= 59 {
-				return cronExprHour
-			}
-		case cronHintDay:
-			if v >= 1 && v <= 31 {
-				return cronExprDay
-			}
-		case cronHintMonth:
-			if v >= 1 && v <= 12 {
-				return cronExprMonth
-			}
-		case cronHintWeek:
-			if v >= 0 && v <= 7 {
-				return cronExprWeek
-			}
-		default:
+	switch {
+	case body == "*":
+		t.Kind = TermAny
+	case strings.Contains(body, "-"):
+		start, end, _ := strings.Cut(body, "-")
+		t.Kind = TermRange
+		if t.Start, err = p.atom(start, pos); err != nil {
+			return
+		}
+		if t.End, err = p.atom(end, pos+len(start)+1); err != nil {
+			return
+		}
+		if t.Start.Value > t.End.Value {
+			err = p.errorf(pos, "range start %s is after its end %s", t.Start, t.End)
+			return
+		}
+	default:
+		t.Kind = TermValue
+		if t.Start, err = p.atom(body, pos); err != nil {
+			return
 		}
-		return cronExprDigit
 	}
 
-	return cronExprUnknown
+	return
 }
 
-func expressionIsValid(s string, h cronHint, validSet []cronExpr) bool {
-	if strings.Contains(s, ",") {
-		list := strings.Split(s, ",")
-		for _, l := range list {
-			if !slices.Contains(validSet, classifyCronExpr(l, h)) {
-				return false
-			}
+// extension parses the body of a term using the syntax enabled by the
+// parser's options, returning false if it uses none
+func (p *fieldParser) extension(t *Term, body string, pos int) (ok bool, err error) {
+	dom := p.field == FieldDayOfTheMonth
+	dow := p.field == FieldDayOfTheWeek
+	upper := strings.ToUpper(body)
+
+	enabled := func(opt ParseOption) bool {
+		if p.opts&opt == 0 {
+			err = p.errorf(pos, "%q is not enabled", body)
 		}
-		return true
+		return err == nil
 	}
 
-	return slices.Contains(validSet, classifyCronExpr(s, h))
-}
+	switch {
+	case body == "?" && (dom || dow):
+		if !enabled(ParseNoSpecificValue) {
+			return true, err
+		}
+		if body != p.text {
+			return true, p.errorf(pos, "`?` must be the only list item")
+		}
+		t.Kind = TermNoValue
+	case upper == "L" && dom:
+		if enabled(ParseLast) {
+			t.Kind = TermLast
+		}
+	case upper == "LW" && dom:
+		if enabled(ParseLast) && enabled(ParseWeekday) {
+			t.Kind = TermLastWeekday
+		}
+	case strings.HasSuffix(upper, "W") && dom:
+		if enabled(ParseWeekday) {
+			t.Kind = TermWeekday
+			t.Start, err = p.atom(body[:len(body)-1], pos)
+		}
+	case strings.HasSuffix(upper, "L") && len(body) > 1 && dow:
+		if enabled(ParseLast) {
+			t.Kind = TermLast
+			t.Start, err = p.atom(body[:len(body)-1], pos)
+		}
+	case strings.Contains(body, "#") && dow:
+		if !enabled(ParseNth) {
+			return true, err
+		}
 
-func minuteIsValid(s string) bool {
-	validSet := []cronExpr{cronExprStar, cronExprStarNthMinute, cronExprMinute,
-		cronExprMinuteRange, cronExprMinuteRangeNth}
-	return expressionIsValid(s, cronHintMinute, validSet)
-}
+		day, nth, _ := strings.Cut(body, "#")
+		t.Kind = TermNth
+		if t.Start, err = p.atom(day, pos); err != nil {
+			return true, err
+		}
+		t.End.Text = nth
+		t.End.Value, _ = strconv.Atoi(nth)
+		if !isDigits(nth) || t.End.Value < 1 || t.End.Value > 5 {
+			err = p.errorf(pos+len(day)+1, "occurrence %q out of range [1, 5]", nth)
+		}
+	default:
+		return false, nil
+	}
 
-func hourIsValid(s string) bool {
-	validSet := []cronExpr{cronExprStar, cronExprStarNthHour, cronExprHour,
-		cronExprHourRange, cronExprHourRangeNth}
-	return expressionIsValid(s, cronHintHour, validSet)
+	return true, err
 }
 
-func dayOfTheMonthIsValid(s string) bool {
-	validSet := []cronExpr{cronExprStar, cronExprDay, cronExprDayRange}
-	return expressionIsValid(s, cronHintDay, validSet)
+// atom parses a number or name starting at the given offset
+func (p *fieldParser) atom(s string, pos int) (a Atom, err error) {
+	a.Text = s
+	if s == "" {
+		err = p.errorf(pos, "missing value")
+		return
+	}
+	if s == "*" {
+		err = p.errorf(pos, "`*` cannot be part of a range")
+		return
+	}
+
+	if isDigits(s) {
+		// NOTE: the length check keeps huge numbers from overflowing
+		if len(s) > 4 {
+			err = p.errorf(pos, "value %s out of range [%d, %d]", s, p.field.Min(), p.field.Max())
+			return
+		}
+		a.Value, _ = strconv.Atoi(s)
+		if a.Value < p.field.Min() || a.Value > p.field.Max() {
+			err = p.errorf(pos, "value %s out of range [%d, %d]", s, p.field.Min(), p.field.Max())
+		}
+		return
+	}
+
+	names := p.field.names()
+	if names == nil {
+		err = p.errorf(pos, "invalid value %q", s)
+		return
+	}
+
+	v, ok := names[strings.ToLower(s)]
+	if !ok {
+		err = p.errorf(pos, "unknown %s name %q", p.field, s)
+		return
+	}
+	a.Value = v
+	return
 }
 
-func monthIsValid(s string) bool {
-	validSet := []cronExpr{cronExprStar, cronExprStarNthMonth, cronExprMonth,
-		cronExprMonthName, cronExprMonthRange, cronExprMonthRangeNth, cronExprMonthNameRangeNth}
-	return expressionIsValid(s, cronHintMonth, validSet)
+// step parses the step of a term starting at the given offset
+func (p *fieldParser) step(s string, pos int) (a Atom, err error) {
+	a.Text = s
+	if s == "" {
+		err = p.errorf(pos, "missing step")
+		return
+	}
+	if !isDigits(s) {
+		err = p.errorf(pos, "invalid step %q", s)
+		return
+	}
+
+	if len(s) <= 4 {
+		a.Value, _ = strconv.Atoi(s)
+	}
+	if a.Value < 1 || a.Value > p.field.Max() || len(s) > 4 {
+		err = p.errorf(pos, "step %s out of range [1, %d]", s, p.field.Max())
+	}
+	return
 }
 
-func dayOfTheWeekIsValid(s string) bool {
-	validSet := []cronExpr{cronExprStar, cronExprWeek,
-		cronExprWeekRange, cronExprWeekNameRange}
-	return expressionIsValid(s, cronHintWeek, validSet)
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
 }
@@ -28,8 +28,13 @@ func NewInstall(comment string) *Install {
 	return &Install{comment: comment}
 }
 
-// Add adds entry to the installer
+// Add adds entry to the installer.
+// The cron must be readable by Vixie cron, as checked by IsVixie
 func (i *Install) Add(c *Cron, user, cmd string) error {
+	if !c.IsVixie() {
+		return fmt.Errorf("cron `%s` is not supported by Vixie cron", c)
+	}
+
 	i.entries = append(i.entries, entry{c, user, cmd})
 	return nil
 }
@@ -0,0 +1,485 @@
+package cron
+
+import (
+	"iter"
+	"slices"
+	"time"
+)
+
+// maxScheduleDays bounds the search for the next or previous run, so
+// that crons that never run, e.g. on February 30th, do not loop forever.
+// It spans the range of the year field
+const maxScheduleDays = 130 * 366
+
+// schedule defines the compiled form of a Cron
+type schedule struct {
+	second, minute, hour, dom, month, dow uint64
+
+	// domTerms and dowTerms hold the terms that depend on the date, e.g.
+	// `L` or `FRI#3`
+	domTerms, dowTerms []Term
+
+	// year is nil if the cron has no year field
+	year *Expr
+
+	// domStar and dowStar are set if the corresponding field starts with
+	// `*`, which makes the day match both fields instead of either
+	domStar, dowStar bool
+
+	// fixed is set if neither the minute nor the hour starts with `*`.
+	// Fixed runs happen once across DST changes, as with cronie
+	fixed bool
+}
+
+// bits returns the set of values matched by the expression
+func (e *Expr) bits() uint64 {
+	var set uint64
+	for _, t := range e.Terms {
+		if t.dependsOnDate() {
+			continue
+		}
+
+		lo, hi := e.Field.Min(), e.Field.Max()
+		switch t.Kind {
+		case TermValue:
+			lo = t.Start.Value
+			if !t.HasStep() {
+				hi = lo
+			}
+		case TermRange:
+			lo, hi = t.Start.Value, t.End.Value
+		}
+
+		step := 1
+		if t.HasStep() {
+			step = t.Step.Value
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << v
+		}
+	}
+
+	// NOTE: Sunday is either 0 or 7
+	if e.Field == FieldDayOfTheWeek && set&(1<<7) != 0 {
+		set = set&^(1<<7) | 1
+	}
+
+	return set
+}
+
+// matches returns true if the value is matched by the expression, which
+// must not depend on the date
+func (e *Expr) matches(v int) bool {
+	for _, t := range e.Terms {
+		lo, hi := e.Field.Min(), e.Field.Max()
+		switch t.Kind {
+		case TermValue:
+			lo = t.Start.Value
+			if !t.HasStep() {
+				hi = lo
+			}
+		case TermRange:
+			lo, hi = t.Start.Value, t.End.Value
+		}
+
+		step := 1
+		if t.HasStep() {
+			step = t.Step.Value
+		}
+		if v >= lo && v <= hi && (v-lo)%step == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// startsWithStar returns true if the expression starts with `*`, as
+// checked by Vixie cron. `?` counts as `*`
+func (e *Expr) startsWithStar() bool {
+	return len(e.Terms) > 0 && (e.Terms[0].Kind == TermAny || e.Terms[0].Kind == TermNoValue)
+}
+
+// dateTerms returns the terms of the expression that depend on the date
+func (e *Expr) dateTerms() (list []Term) {
+	for _, t := range e.Terms {
+		if t.dependsOnDate() {
+			list = append(list, t)
+		}
+	}
+	return
+}
+
+func (t Term) dependsOnDate() bool {
+	switch t.Kind {
+	case TermLast, TermWeekday, TermLastWeekday, TermNth:
+		return true
+	}
+	return false
+}
+
+// matchesDay returns true if the term, of the given day field, matches
+// the date
+func (t Term) matchesDay(f Field, date time.Time) bool {
+	year, month, day := date.Date()
+	last := daysIn(year, month)
+
+	if f == FieldDayOfTheWeek {
+		if int(date.Weekday()) != t.Start.Value%7 {
+			return false
+		}
+		switch t.Kind {
+		case TermLast:
+			return day+7 > last
+		case TermNth:
+			return (day-1)/7+1 == t.End.Value
+		}
+		return false
+	}
+
+	switch t.Kind {
+	case TermLast:
+		return day == last
+	case TermWeekday:
+		return day == nearestWeekday(year, month, t.Start.Value)
+	case TermLastWeekday:
+		return day == nearestWeekday(year, month, last)
+	}
+	return false
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// nearestWeekday returns the weekday nearest to the given day without
+// leaving its month, or 0 if the month has no such day
+func nearestWeekday(year int, month time.Month, day int) int {
+	last := daysIn(year, month)
+	if day > last {
+		return 0
+	}
+
+	switch time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return day + 2
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
+
+func (c *Cron) schedule() (*schedule, error) {
+	fields, err := c.Fields()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &schedule{
+		second:   1,
+		minute:   fields[FieldMinute].bits(),
+		hour:     fields[FieldHour].bits(),
+		dom:      fields[FieldDayOfTheMonth].bits(),
+		month:    fields[FieldMonth].bits(),
+		dow:      fields[FieldDayOfTheWeek].bits(),
+		domTerms: fields[FieldDayOfTheMonth].dateTerms(),
+		dowTerms: fields[FieldDayOfTheWeek].dateTerms(),
+		year:     fields[FieldYear],
+		domStar:  fields[FieldDayOfTheMonth].startsWithStar(),
+		dowStar:  fields[FieldDayOfTheWeek].startsWithStar(),
+		fixed:    !fields[FieldMinute].startsWithStar() && !fields[FieldHour].startsWithStar(),
+	}
+	if fields[FieldSecond] != nil {
+		s.second = fields[FieldSecond].bits()
+	}
+	return s, nil
+}
+
+// Next returns the first time after from when the cron runs, in from's
+// location. It returns the zero time if the cron is invalid or never runs.
+//
+// Times skipped by a DST change run when it ends if the cron is fixed,
+// i.e. neither its minute nor its hour starts with `*`, and are skipped
+// otherwise. Repeated times run once if the cron is fixed, and twice
+// otherwise.
+//
+// An `@every` cron runs every interval after from, truncated to the
+// second, and a `@reboot` one never does
+func (c *Cron) Next(from time.Time) time.Time {
+	return c.nextFunc()(from)
+}
+
+// Prev returns the last time before from when the cron ran, in from's
+// location. It returns the zero time if the cron is invalid or never
+// ran. DST changes and macros are handled as in Next
+func (c *Cron) Prev(from time.Time) time.Time {
+	if c.Every > 0 {
+		t := from.Truncate(time.Second)
+		if t.Before(from) {
+			t = t.Add(time.Second)
+		}
+		return t.Add(-c.Every)
+	}
+
+	s, err := c.schedule()
+	if err != nil {
+		return time.Time{}
+	}
+	return s.prev(from)
+}
+
+// Runs returns the times after from when the cron runs, in order
+func (c *Cron) Runs(from time.Time) iter.Seq[time.Time] {
+	next := c.nextFunc()
+	return func(yield func(time.Time) bool) {
+		for t := next(from); !t.IsZero(); t = next(t) {
+			if !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// NextN returns the next n times after from when the cron runs
+func (c *Cron) NextN(from time.Time, n int) []time.Time {
+	list := make([]time.Time, 0, max(n, 0))
+	for t := range c.Runs(from) {
+		if len(list) >= n {
+			break
+		}
+		list = append(list, t)
+	}
+	return list
+}
+
+// nextFunc returns the function computing Next, compiling the cron once
+func (c *Cron) nextFunc() func(time.Time) time.Time {
+	if c.Every > 0 {
+		return func(from time.Time) time.Time {
+			return from.Truncate(time.Second).Add(c.Every)
+		}
+	}
+
+	s, err := c.schedule()
+	if err != nil {
+		return func(time.Time) time.Time { return time.Time{} }
+	}
+	return s.next
+}
+
+// next returns the first run after from, in from's location
+func (s *schedule) next(from time.Time) time.Time {
+	// NOTE: start the day before, since DST changes may map its last
+	// runs after from
+	y, m, d := from.Date()
+	for i := -1; i < maxScheduleDays; i++ {
+		if t, ok := s.find(y, m, d+i, from, false); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// prev returns the last run before from, in from's location
+func (s *schedule) prev(from time.Time) time.Time {
+	y, m, d := from.Date()
+	for i := 1; i > -maxScheduleDays; i-- {
+		if t, ok := s.find(y, m, d+i, from, true); ok {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches returns true if the cron runs on the given date, applying
+// Vixie cron's rule: if either day field starts with `*`, both must
+// match, otherwise either does
+func (s *schedule) dayMatches(t time.Time) bool {
+	if s.month&(1<<int(t.Month())) == 0 {
+		return false
+	}
+	if s.year != nil && !s.year.matches(t.Year()) {
+		return false
+	}
+
+	dom := s.dom&(1<<t.Day()) != 0
+	for _, term := range s.domTerms {
+		dom = dom || term.matchesDay(FieldDayOfTheMonth, t)
+	}
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	for _, term := range s.dowTerms {
+		dow = dow || term.matchesDay(FieldDayOfTheWeek, t)
+	}
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// find returns the first run on the given date after from, or the last
+// one before from if backward. The date is normalized as time.Date does
+func (s *schedule) find(year int, month time.Month, day int, from time.Time, backward bool) (time.Time, bool) {
+	loc := from.Location()
+	date := time.Date(year, month, day, 12, 0, 0, 0, time.UTC)
+	if !s.dayMatches(date) {
+		return time.Time{}, false
+	}
+
+	year, month, day = date.Date()
+	start := startOfDay(year, month, day, loc)
+	next := startOfDay(year, month, day+1, loc)
+
+	found := func(t time.Time) bool {
+		if backward {
+			return t.Before(from)
+		}
+		return t.After(from)
+	}
+
+	// NOTE: without a DST change, wall times map linearly to instants, so
+	// whole hours and minutes out of reach are skipped
+	_, startOffset := start.Zone()
+	_, nextOffset := next.Zone()
+	if startOffset != nextOffset || start.Hour() != 0 || start.Minute() != 0 || start.Second() != 0 {
+		runs := s.runs(year, month, day, loc)
+		if backward {
+			slices.Reverse(runs)
+		}
+		for _, t := range runs {
+			if found(t) {
+				return t, true
+			}
+		}
+		return time.Time{}, false
+	}
+
+	reachable := func(lo time.Time, d time.Duration) bool {
+		if backward {
+			return lo.Before(from)
+		}
+		return lo.Add(d).After(from)
+	}
+
+	for h := range units(24, backward) {
+		hs := start.Add(time.Duration(h) * time.Hour)
+		if s.hour&(1<<h) == 0 || !reachable(hs, time.Hour) {
+			continue
+		}
+		for m := range units(60, backward) {
+			ms := hs.Add(time.Duration(m) * time.Minute)
+			if s.minute&(1<<m) == 0 || !reachable(ms, time.Minute) {
+				continue
+			}
+			for sec := range units(60, backward) {
+				t := ms.Add(time.Duration(sec) * time.Second)
+				if s.second&(1<<sec) != 0 && found(t) {
+					return t, true
+				}
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// startOfDay returns the first instant of the given date in loc, which is
+// the end of the DST gap if the day starts in one
+func startOfDay(year int, month time.Month, day int, loc *time.Location) time.Time {
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if y, m, d := t.Date(); y != year || m != month || d != day {
+		_, end := t.ZoneBounds()
+		return end
+	}
+	return t
+}
+
+// units returns the numbers from 0 to n-1, in order or backward
+func units(n int, backward bool) iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for i := range n {
+			if backward {
+				i = n - 1 - i
+			}
+			if !yield(i) {
+				return
+			}
+		}
+	}
+}
+
+// runs returns the times, in order, when the cron runs on the given
+// date, across a DST change
+func (s *schedule) runs(year int, month time.Month, day int, loc *time.Location) []time.Time {
+	var list []time.Time
+	for h := range 24 {
+		if s.hour&(1<<h) == 0 {
+			continue
+		}
+		for m := range 60 {
+			if s.minute&(1<<m) == 0 {
+				continue
+			}
+			for sec := range 60 {
+				if s.second&(1<<sec) != 0 {
+					list = append(list, s.resolve(year, month, day, h, m, sec, loc)...)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(list, func(a, b time.Time) int { return a.Compare(b) })
+	return slices.CompactFunc(list, time.Time.Equal)
+}
+
+// resolve returns the instants for the given wall time, which can be
+// none or two across DST changes, as described in Next
+func (s *schedule) resolve(year int, month time.Month, day, hour, min, sec int, loc *time.Location) []time.Time {
+	wall := time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	t := time.Date(year, month, day, hour, min, sec, 0, loc)
+
+	sameWall := func(u time.Time) bool {
+		y, mo, d := u.Date()
+		return y == year && mo == month && d == day &&
+			u.Hour() == hour && u.Minute() == min && u.Second() == sec
+	}
+
+	if !sameWall(t) {
+		if !s.fixed {
+			return nil
+		}
+
+		// NOTE: the wall time is in a DST gap, so run when the gap ends,
+		// i.e. when the zone of t starts, or ends if t is before the gap
+		zoneStart, zoneEnd := t.ZoneBounds()
+		tWall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+		if tWall.After(wall) {
+			return []time.Time{zoneStart}
+		}
+		return []time.Time{zoneEnd}
+	}
+
+	var list []time.Time
+	for _, u := range []time.Time{t.Add(-24 * time.Hour), t, t.Add(24 * time.Hour)} {
+		c := wall.Add(-offsetOf(u)).In(loc)
+		if sameWall(c) && !slices.ContainsFunc(list, c.Equal) {
+			list = append(list, c)
+		}
+	}
+	slices.SortFunc(list, func(a, b time.Time) int { return a.Compare(b) })
+
+	if s.fixed && len(list) > 1 {
+		list = list[:1]
+	}
+	return list
+}
+
+func offsetOf(t time.Time) time.Duration {
+	_, offset := t.Zone()
+	return time.Duration(offset) * time.Second
+}
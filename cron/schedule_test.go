@@ -0,0 +1,323 @@
+package cron
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+
+	// NOTE: in 2018, DST in São Paulo started at midnight on November 4th
+	sp, err := time.LoadLocation("America/Sao_Paulo")
+	assert.NoError(t, err)
+
+	at := func(loc *time.Location, y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	testCases := []struct {
+		name string
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "steps",
+			expr: "*/15 * * * *",
+			from: at(time.UTC, 2024, 1, 1, 10, 7),
+			want: []time.Time{at(time.UTC, 2024, 1, 1, 10, 15), at(time.UTC, 2024, 1, 1, 10, 30)},
+		},
+		{
+			name: "exact from is excluded",
+			expr: "0 10 * * *",
+			from: at(time.UTC, 2024, 1, 1, 10, 0),
+			want: []time.Time{at(time.UTC, 2024, 1, 2, 10, 0)},
+		},
+		{
+			name: "day of the month or week",
+			expr: "0 0 13 * FRI",
+			from: at(time.UTC, 2024, 1, 1, 0, 0),
+			want: []time.Time{
+				at(time.UTC, 2024, 1, 5, 0, 0),
+				at(time.UTC, 2024, 1, 12, 0, 0),
+				at(time.UTC, 2024, 1, 13, 0, 0),
+				at(time.UTC, 2024, 1, 19, 0, 0),
+			},
+		},
+		{
+			name: "day of the month and week with star",
+			expr: "0 0 */2 * MON",
+			from: at(time.UTC, 2024, 1, 1, 0, 0),
+			want: []time.Time{at(time.UTC, 2024, 1, 15, 0, 0), at(time.UTC, 2024, 1, 29, 0, 0)},
+		},
+		{
+			name: "Sunday as 7",
+			expr: "0 0 * * 7",
+			from: at(time.UTC, 2024, 1, 1, 0, 0),
+			want: []time.Time{at(time.UTC, 2024, 1, 7, 0, 0)},
+		},
+		{
+			name: "leap day",
+			expr: "0 0 29 2 *",
+			from: at(time.UTC, 2025, 1, 1, 0, 0),
+			want: []time.Time{at(time.UTC, 2028, 2, 29, 0, 0)},
+		},
+		{
+			name: "never",
+			expr: "0 0 30 2 *",
+			from: at(time.UTC, 2024, 1, 1, 0, 0),
+		},
+		{
+			name: "time zone",
+			expr: "0 9 * * *",
+			from: at(time.UTC, 2024, 1, 1, 0, 30),
+			want: []time.Time{at(time.UTC, 2024, 1, 1, 9, 0)},
+		},
+		{
+			name: "other time zone",
+			expr: "0 9 * * *",
+			from: at(time.UTC, 2024, 1, 1, 0, 30).In(tokyo),
+			want: []time.Time{at(tokyo, 2024, 1, 2, 9, 0)},
+		},
+		{
+			name: "fixed in DST gap",
+			expr: "30 2 * * *",
+			from: at(ny, 2024, 3, 10, 0, 0),
+			want: []time.Time{at(ny, 2024, 3, 10, 3, 0), at(ny, 2024, 3, 11, 2, 30)},
+		},
+		{
+			name: "wildcard in DST gap",
+			expr: "30 * * * *",
+			from: at(ny, 2024, 3, 10, 1, 45),
+			want: []time.Time{at(ny, 2024, 3, 10, 3, 30)},
+		},
+		{
+			name: "fixed in DST overlap",
+			expr: "30 1 * * *",
+			from: at(ny, 2024, 11, 3, 0, 0),
+			want: []time.Time{at(ny, 2024, 11, 3, 1, 30), at(ny, 2024, 11, 4, 1, 30)},
+		},
+		{
+			name: "wildcard in DST overlap",
+			expr: "30 * * * *",
+			from: at(ny, 2024, 11, 3, 0, 45),
+			want: []time.Time{
+				at(ny, 2024, 11, 3, 1, 30),
+				at(ny, 2024, 11, 3, 1, 30).Add(time.Hour),
+				at(ny, 2024, 11, 3, 2, 30),
+			},
+		},
+		{
+			name: "day starting in a DST gap",
+			expr: "0 12 4 11 *",
+			from: at(sp, 2018, 11, 1, 0, 0),
+			want: []time.Time{at(sp, 2018, 11, 4, 12, 0), at(sp, 2019, 11, 4, 12, 0)},
+		},
+		{
+			name: "fixed at midnight in DST gap",
+			expr: "0 0 * * *",
+			from: at(sp, 2018, 11, 3, 12, 0),
+			want: []time.Time{at(sp, 2018, 11, 4, 1, 0), at(sp, 2018, 11, 5, 0, 0)},
+		},
+		{
+			name: "wildcard across midnight DST gap",
+			expr: "*/30 * * * *",
+			from: at(sp, 2018, 11, 3, 23, 0),
+			want: []time.Time{at(sp, 2018, 11, 3, 23, 30), at(sp, 2018, 11, 4, 1, 0), at(sp, 2018, 11, 4, 1, 30)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Parse(tc.expr + " cmd")
+			assert.NoError(t, err)
+
+			got := c.NextN(tc.from, len(tc.want))
+			assert.Equal(t, len(tc.want), len(got))
+			for i := range tc.want {
+				assert.Equal(t, tc.want[i].String(), got[i].String())
+			}
+
+			if len(tc.want) == 0 {
+				assert.Equal(t, true, c.Next(tc.from).IsZero())
+				return
+			}
+
+			// NOTE: Prev walks the same runs backwards
+			last := got[len(got)-1]
+			for i := len(got) - 2; i >= 0; i-- {
+				last = c.Prev(last)
+				assert.Equal(t, got[i].String(), last.String())
+			}
+		})
+	}
+}
+
+func TestPrev(t *testing.T) {
+	c, err := Parse("0 9 * * MON-FRI cmd")
+	assert.NoError(t, err)
+
+	got := c.Prev(time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC), got)
+
+	invalid := &Cron{Minute: "61", Hour: "*", DayOfTheMonth: "*", Month: "*", DayOfTheWeek: "*"}
+	assert.Equal(t, true, invalid.Prev(got).IsZero())
+	assert.Equal(t, true, invalid.Next(got).IsZero())
+}
+
+func TestRuns(t *testing.T) {
+	c, err := Parse("0 */6 * * * cmd")
+	assert.NoError(t, err)
+
+	from := time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC)
+	var got []int
+	for r := range c.Runs(from) {
+		got = append(got, r.Hour())
+		if len(got) == 5 {
+			break
+		}
+	}
+	assert.Equal(t, []int{6, 12, 18, 0, 6}, got)
+}
+
+func TestNextExtensions(t *testing.T) {
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name string
+		opts ParseOption
+		expr string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			name: "last day of the month",
+			opts: ParseLast,
+			expr: "0 0 L * *",
+			from: day(2024, 1, 1),
+			want: []time.Time{day(2024, 1, 31), day(2024, 2, 29), day(2024, 3, 31)},
+		},
+		{
+			name: "last friday",
+			opts: ParseLast,
+			expr: "0 0 * * FRIL",
+			from: day(2024, 1, 1),
+			want: []time.Time{day(2024, 1, 26), day(2024, 2, 23)},
+		},
+		{
+			name: "nearest weekday",
+			opts: ParseWeekday,
+			expr: "0 0 15W * *",
+			from: day(2024, 5, 31),
+			want: []time.Time{day(2024, 6, 14), day(2024, 7, 15), day(2024, 8, 15), day(2024, 9, 16)},
+		},
+		{
+			name: "nearest weekday stays in the month",
+			opts: ParseWeekday,
+			expr: "0 0 1W * *",
+			from: day(2024, 5, 31),
+			want: []time.Time{day(2024, 6, 3)},
+		},
+		{
+			name: "last weekday",
+			opts: ParseLast | ParseWeekday,
+			expr: "0 0 LW * *",
+			from: day(2024, 8, 1),
+			want: []time.Time{day(2024, 8, 30), day(2024, 9, 30)},
+		},
+		{
+			name: "nth",
+			opts: ParseNth,
+			expr: "0 0 * * 0#2,MON#1",
+			from: day(2024, 1, 1),
+			want: []time.Time{day(2024, 1, 14), day(2024, 2, 5), day(2024, 2, 11)},
+		},
+		{
+			name: "nth or day of the month",
+			opts: ParseNth,
+			expr: "0 0 1 * SUN#2",
+			from: day(2024, 1, 1),
+			want: []time.Time{day(2024, 1, 14), day(2024, 2, 1), day(2024, 2, 11)},
+		},
+		{
+			name: "no specific value",
+			opts: ParseNoSpecificValue,
+			expr: "0 0 ? * MON",
+			from: day(2024, 1, 1),
+			want: []time.Time{day(2024, 1, 8), day(2024, 1, 15)},
+		},
+		{
+			name: "seconds",
+			opts: ParseSeconds,
+			expr: "*/20 0 0 * * *",
+			from: day(2024, 1, 1),
+			want: []time.Time{
+				day(2024, 1, 1).Add(20 * time.Second),
+				day(2024, 1, 1).Add(40 * time.Second),
+				day(2024, 1, 2),
+			},
+		},
+		{
+			name: "year",
+			opts: ParseYear,
+			expr: "0 0 1 1 * 2030/5",
+			from: day(2024, 1, 1),
+			want: []time.Time{day(2030, 1, 1), day(2035, 1, 1)},
+		},
+		{
+			name: "past year",
+			opts: ParseYear,
+			expr: "0 0 1 1 * 2020",
+			from: day(2024, 1, 1),
+			want: []time.Time{},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := NewParser(tc.opts).Parse(tc.expr)
+			assert.NoError(t, err)
+
+			got := c.NextN(tc.from, len(tc.want))
+			assert.Equal(t, tc.want, got)
+
+			if len(got) > 1 {
+				assert.Equal(t, got[len(got)-2], c.Prev(got[len(got)-1]))
+			}
+		})
+	}
+}
+
+func TestNextSecondsInDSTGap(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	c, err := NewParser(ParseSeconds).Parse("30 30 2 * * *")
+	assert.NoError(t, err)
+
+	got := c.Next(time.Date(2024, 3, 10, 0, 0, 0, 0, ny))
+	assert.Equal(t, time.Date(2024, 3, 10, 3, 0, 0, 0, ny).String(), got.String())
+}
+
+func TestRunsEverySecond(t *testing.T) {
+	c, err := NewParser(ParseQuartz).Parse("* * * * * ?")
+	assert.NoError(t, err)
+
+	from := time.Date(2024, 1, 1, 23, 59, 58, 500, time.UTC)
+	got := c.NextN(from, 100)
+	assert.Equal(t, 100, len(got))
+	for i, r := range got {
+		assert.Equal(t, from.Truncate(time.Second).Add(time.Duration(i+1)*time.Second), r)
+	}
+
+	assert.Equal(t, time.Date(2024, 1, 1, 23, 59, 58, 0, time.UTC), c.Prev(from))
+}
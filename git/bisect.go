@@ -0,0 +1,253 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+// BisectTerm defines how a revision is marked while bisecting.
+type BisectTerm string
+
+// Supported bisect terms.
+const (
+	BisectGood BisectTerm = "good"
+	BisectBad  BisectTerm = "bad"
+	BisectSkip BisectTerm = "skip"
+)
+
+// BisectOptions defines the options supported by BisectStart.
+type BisectOptions struct {
+	// Bad is a revision known to be bad. It defaults to HEAD.
+	Bad string `json:"bad,omitempty"`
+
+	// Good are revisions known to be good. At least one is required.
+	Good []string `json:"good"`
+
+	// Pathspecs, if given, restricts the bisection to the commits
+	// touching the matching paths.
+	Pathspecs []string `json:"pathspecs,omitempty"`
+}
+
+// BisectStep defines the state of a bisection after starting it or
+// marking a revision.
+type BisectStep struct {
+	// Current is the hash of the revision to test next, which is
+	// checked out in the working tree.
+	Current string `json:"current,omitempty"`
+
+	// FirstBad is the hash of the first bad commit, once found.
+	FirstBad string `json:"firstBad,omitempty"`
+}
+
+// Done returns true if the first bad commit was found.
+func (s BisectStep) Done() bool {
+	return s.FirstBad != ""
+}
+
+// BisectResult defines the outcome of a completed bisection.
+type BisectResult struct {
+	FirstBad LogEntry `json:"firstBad"`
+
+	// Log is the bisect log, as reported by `git bisect log`.
+	Log string `json:"log"`
+}
+
+// BisectFunc tests the given revision, checked out in the working tree,
+// and tells how to mark it.
+type BisectFunc func(ctx context.Context, hash string) (BisectTerm, error)
+
+// BisectInconclusiveError is returned when only skipped commits are left
+// to test.
+type BisectInconclusiveError struct {
+	// Candidates are the commits that could be the first bad one.
+	Candidates []string
+}
+
+func (e *BisectInconclusiveError) Error() string {
+	return fmt.Sprintf("only skipped commits left to test, the first bad commit could be any of: %s",
+		strings.Join(e.Candidates, ", "))
+}
+
+var (
+	bisectFirstBad   = regexp.MustCompile(`(?m)^([0-9a-f]{40,64}) is the first bad commit$`)
+	bisectCandidates = regexp.MustCompile(`(?m)^[0-9a-f]{40,64}$`)
+)
+
+func (h *handlerImpl) Bisect(ctx context.Context, opts BisectOptions, test BisectFunc) (result BisectResult, err error) {
+	h.log.With(
+		"bad", opts.Bad,
+		"good", opts.Good,
+		"pathspecs", opts.Pathspecs,
+	).Info("Bisecting")
+
+	step, err := h.BisectStart(opts)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if rerr := h.BisectReset(); rerr != nil && err == nil {
+			err = rerr
+		}
+	}()
+
+	for !step.Done() {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		var term BisectTerm
+		if term, err = test(ctx, step.Current); err != nil {
+			err = fmt.Errorf("testing %s: %w", step.Current, err)
+			return
+		}
+
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		if step, err = h.BisectMark(term); err != nil {
+			return
+		}
+	}
+
+	if result.Log, err = h.BisectLog(); err != nil {
+		return
+	}
+
+	list, err := h.logEntries("log", "--max-count=1", step.FirstBad)
+	if err != nil {
+		return
+	}
+	if len(list) == 0 {
+		err = fmt.Errorf("first bad commit not found: %s", step.FirstBad)
+		return
+	}
+
+	result.FirstBad = list[0]
+	return
+}
+
+func (h *handlerImpl) BisectLog() (string, error) {
+	h.log.Info("Getting bisect log")
+
+	out, err := h.execute("bisect", "log")
+	return string(out), err
+}
+
+func (h *handlerImpl) BisectMark(term BisectTerm, revs ...string) (step BisectStep, err error) {
+	h.log.With(
+		"term", term,
+		"revs", revs,
+	).Info("Marking bisect revisions")
+
+	switch term {
+	case BisectGood, BisectBad, BisectSkip:
+	default:
+		err = fmt.Errorf("unsupported bisect term: %q", term)
+		return
+	}
+
+	args := []string{"bisect", string(term)}
+	args = append(args, revs...)
+
+	return h.bisectStep(args...)
+}
+
+func (h *handlerImpl) BisectReset() error {
+	h.log.Info("Resetting bisect state")
+
+	return h.executeNO("bisect", "reset")
+}
+
+func (h *handlerImpl) BisectRun(ctx context.Context, opts BisectOptions, name string, args ...string) (BisectResult, error) {
+	h.log.With(
+		"name", name,
+		"args", args,
+	).Info("Bisecting with command")
+
+	return h.Bisect(ctx, opts, func(ctx context.Context, _ string) (BisectTerm, error) {
+		cmd := exec.CommandContext(ctx, name, args...)
+		cmd.Dir = h.root
+		cmd.Env = h.environ(nil)
+
+		err := cmd.Run()
+		if err == nil {
+			return BisectGood, nil
+		}
+
+		// NOTE: same exit code semantics as `git bisect run`
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || ctx.Err() != nil {
+			return "", err
+		}
+
+		switch code := exitErr.ExitCode(); {
+		case code == 125:
+			return BisectSkip, nil
+		case code > 0 && code < 128:
+			return BisectBad, nil
+		default:
+			return "", err
+		}
+	})
+}
+
+func (h *handlerImpl) BisectStart(opts BisectOptions) (step BisectStep, err error) {
+	h.log.With(
+		"bad", opts.Bad,
+		"good", opts.Good,
+		"pathspecs", opts.Pathspecs,
+	).Info("Starting bisect")
+
+	if len(opts.Good) == 0 {
+		err = errors.New("at least one good revision is required to bisect")
+		return
+	}
+
+	if opts.Bad == "" {
+		opts.Bad = "HEAD"
+	}
+
+	args := []string{"bisect", "start", opts.Bad}
+	args = append(args, opts.Good...)
+	args = append(args, "--")
+	args = append(args, opts.Pathspecs...)
+
+	if step, err = h.bisectStep(args...); err != nil {
+		_ = h.BisectReset()
+	}
+	return
+}
+
+// bisectStep runs the given bisect command, and returns the resulting
+// state of the bisection.
+func (h *handlerImpl) bisectStep(in ...string) (step BisectStep, err error) {
+	out, err := h.execute(in...)
+	if err != nil {
+		if bytes.Contains(out, []byte("only 'skip'ped commits left")) {
+			err = &BisectInconclusiveError{
+				Candidates: bisectCandidates.FindAllString(string(out), -1),
+			}
+		}
+		return
+	}
+
+	if m := bisectFirstBad.FindSubmatch(out); m != nil {
+		step.FirstBad = string(m[1])
+		return
+	}
+
+	out, err = h.execute("rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return
+	}
+
+	step.Current = strings.TrimSpace(string(out))
+	return
+}
@@ -0,0 +1,124 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestBisect(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "version.txt")
+	var hashes []string
+	for i := 1; i <= 8; i++ {
+		err := os.WriteFile(file, []byte(strconv.Itoa(i)), 0644)
+		assert.NoError(t, err)
+
+		hash, err := g.CommitWithOptions(CommitOptions{
+			Message: "Version " + strconv.Itoa(i),
+			Files:   []string{file},
+		})
+		assert.NoError(t, err)
+		hashes = append(hashes, hash)
+	}
+
+	version := func() int {
+		b, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		v, err := strconv.Atoi(string(b))
+		assert.NoError(t, err)
+		return v
+	}
+
+	opts := BisectOptions{Good: []string{hashes[0]}}
+
+	t.Run("Callback", func(t *testing.T) {
+		result, err := g.Bisect(context.Background(), opts, func(_ context.Context, _ string) (BisectTerm, error) {
+			if version() >= 6 {
+				return BisectBad, nil
+			}
+			return BisectGood, nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, hashes[5], result.FirstBad.Hash)
+		assert.Equal(t, "Version 6", result.FirstBad.Subject)
+		assert.Equal(t, true, strings.Contains(result.Log, "# first bad commit: ["+hashes[5]+"]"))
+
+		branch, err := g.Branch()
+		assert.NoError(t, err)
+		assert.Equal(t, "main", branch)
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		result, err := g.BisectRun(context.Background(), opts, "grep", "-qv", "^[78]$", "version.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, hashes[6], result.FirstBad.Hash)
+	})
+
+	t.Run("Command environment", func(t *testing.T) {
+		t.Setenv("BISECT_LEAK", "1")
+
+		err := g.SetEnvOptions(EnvOptions{Env: []string{"FIRST_BAD=4"}, IsolateEnv: true})
+		assert.NoError(t, err)
+		defer g.SetEnvOptions(EnvOptions{})
+
+		script := `test -z "$BISECT_LEAK" && test "$(cat version.txt)" -lt "$FIRST_BAD"`
+		result, err := g.BisectRun(context.Background(), opts, "sh", "-c", script)
+		assert.NoError(t, err)
+		assert.Equal(t, hashes[3], result.FirstBad.Hash)
+	})
+
+	t.Run("Manual", func(t *testing.T) {
+		step, err := g.BisectStart(BisectOptions{Bad: hashes[3], Good: []string{hashes[1]}})
+		assert.NoError(t, err)
+		assert.Equal(t, hashes[2], step.Current)
+
+		step, err = g.BisectMark(BisectSkip)
+		var inconclusive *BisectInconclusiveError
+		assert.Equal(t, true, errors.As(err, &inconclusive))
+		assert.Equal(t, []string{hashes[2], hashes[3]}, inconclusive.Candidates)
+
+		err = g.BisectReset()
+		assert.NoError(t, err)
+
+		_, err = g.BisectStart(BisectOptions{Bad: hashes[3], Good: []string{hashes[1]}})
+		assert.NoError(t, err)
+
+		step, err = g.BisectMark(BisectGood)
+		assert.NoError(t, err)
+		assert.Equal(t, true, step.Done())
+		assert.Equal(t, hashes[3], step.FirstBad)
+
+		err = g.BisectReset()
+		assert.NoError(t, err)
+	})
+
+	t.Run("Error resets", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		_, err := g.Bisect(ctx, opts, func(_ context.Context, _ string) (BisectTerm, error) {
+			cancel()
+			return BisectGood, nil
+		})
+		assert.Equal(t, true, errors.Is(err, context.Canceled))
+
+		_, err = g.Bisect(context.Background(), opts, func(_ context.Context, _ string) (BisectTerm, error) {
+			return "", errors.New("broken build")
+		})
+		assert.Error(t, err)
+
+		_, err = g.BisectLog()
+		assert.Error(t, err)
+
+		branch, err := g.Branch()
+		assert.NoError(t, err)
+		assert.Equal(t, "main", branch)
+	})
+}
@@ -0,0 +1,144 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Special attribute values, as reported by git-check-attr(1).
+const (
+	AttrSet         = "set"
+	AttrUnset       = "unset"
+	AttrUnspecified = "unspecified"
+)
+
+// IgnoreResult defines whether a path is ignored and, if any pattern
+// matched it, where that pattern came from.
+type IgnoreResult struct {
+	Path    string `json:"path"`
+	Ignored bool   `json:"ignored"`
+
+	// Source is the file containing the matching pattern, e.g. `.gitignore`.
+	Source string `json:"source,omitempty"`
+
+	// Line is the line number of the matching pattern in Source.
+	Line int `json:"line,omitempty"`
+
+	// Pattern is the matching pattern. A negated one (`!pattern`) means
+	// that the path is not ignored.
+	Pattern string `json:"pattern,omitempty"`
+}
+
+// PathAttributes defines the gitattributes for a path.
+type PathAttributes struct {
+	Path string `json:"path"`
+
+	// Attributes maps each attribute to either its value, AttrSet,
+	// AttrUnset or AttrUnspecified.
+	Attributes map[string]string `json:"attributes"`
+}
+
+func (h *handlerImpl) CheckAttr(paths []string, attrs ...string) ([]PathAttributes, error) {
+	h.log.With(
+		"paths", len(paths),
+		"attrs", attrs,
+	).Info("Checking attributes")
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	args := []string{"check-attr", "-z", "--stdin"}
+	if len(attrs) > 0 {
+		args = append(args, attrs...)
+	} else {
+		args = append(args, "--all")
+	}
+
+	out, err := h.executeStdin(nulReader(paths), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]PathAttributes, 0, len(paths))
+	index := make(map[string]int, len(paths))
+	for _, p := range paths {
+		if _, ok := index[p]; ok {
+			continue
+		}
+		index[p] = len(list)
+		list = append(list, PathAttributes{Path: p, Attributes: map[string]string{}})
+	}
+
+	// Each record is `path NUL attribute NUL info NUL`.
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		idx, ok := index[fields[i]]
+		if !ok {
+			idx = len(list)
+			index[fields[i]] = idx
+			list = append(list, PathAttributes{Path: fields[i], Attributes: map[string]string{}})
+		}
+		list[idx].Attributes[fields[i+1]] = fields[i+2]
+	}
+
+	return list, nil
+}
+
+func (h *handlerImpl) CheckIgnore(paths []string) ([]IgnoreResult, error) {
+	h.log.Info("Checking ignored paths", "paths", len(paths))
+
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	out, err := h.executeStdin(
+		nulReader(paths),
+		"check-ignore", "-z", "--stdin", "--verbose", "--non-matching",
+	)
+	if err != nil {
+		// NOTE: exit code 1 means that none of the paths is ignored
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+			return nil, err
+		}
+	}
+
+	// Each record is `source NUL line NUL pattern NUL path NUL`,
+	// the first three being empty for paths that do not match.
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+
+	list := make([]IgnoreResult, 0, len(paths))
+	for i := 0; i+3 < len(fields); i += 4 {
+		r := IgnoreResult{
+			Path:    fields[i+3],
+			Source:  fields[i],
+			Pattern: fields[i+2],
+		}
+
+		if fields[i+1] != "" {
+			if r.Line, err = strconv.Atoi(fields[i+1]); err != nil {
+				return nil, fmt.Errorf("invalid line number for %q: %w", r.Path, err)
+			}
+		}
+
+		r.Ignored = r.Pattern != "" && !strings.HasPrefix(r.Pattern, "!")
+		list = append(list, r)
+	}
+
+	return list, nil
+}
+
+// nulReader returns a reader with the given paths, NUL-terminated, as
+// expected by `--stdin` when combined with `-z`.
+func nulReader(paths []string) *strings.Reader {
+	var sb strings.Builder
+	for _, p := range paths {
+		sb.WriteString(p)
+		sb.WriteByte(0)
+	}
+	return strings.NewReader(sb.String())
+}
@@ -0,0 +1,71 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestCheckAttr(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	attrs := "*.txt eol=lf export-ignore\n*.bin -diff\n"
+	err := os.WriteFile(filepath.Join(dir, ".gitattributes"), []byte(attrs), 0644)
+	assert.NoError(t, err)
+
+	list, err := g.CheckAttr([]string{"a.txt", "b.bin", "c.go"}, "eol", "export-ignore", "diff")
+	assert.NoError(t, err)
+	assert.Equal(t, []PathAttributes{
+		{
+			Path: "a.txt",
+			Attributes: map[string]string{
+				"eol":           "lf",
+				"export-ignore": AttrSet,
+				"diff":          AttrUnspecified,
+			},
+		},
+		{
+			Path: "b.bin",
+			Attributes: map[string]string{
+				"eol":           AttrUnspecified,
+				"export-ignore": AttrUnspecified,
+				"diff":          AttrUnset,
+			},
+		},
+		{
+			Path: "c.go",
+			Attributes: map[string]string{
+				"eol":           AttrUnspecified,
+				"export-ignore": AttrUnspecified,
+				"diff":          AttrUnspecified,
+			},
+		},
+	}, list)
+
+	list, err = g.CheckAttr([]string{"a.txt"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"eol": "lf", "export-ignore": AttrSet}, list[0].Attributes)
+}
+
+func TestCheckIgnore(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n!keep.log\n"), 0644)
+	assert.NoError(t, err)
+
+	list, err := g.CheckIgnore([]string{"a.log", "keep.log", "file with spaces.txt"})
+	assert.NoError(t, err)
+	assert.Equal(t, []IgnoreResult{
+		{Path: "a.log", Ignored: true, Source: ".gitignore", Line: 1, Pattern: "*.log"},
+		{Path: "keep.log", Source: ".gitignore", Line: 2, Pattern: "!keep.log"},
+		{Path: "file with spaces.txt"},
+	}, list)
+
+	list, err = g.CheckIgnore([]string{"b.txt"})
+	assert.NoError(t, err)
+	assert.Equal(t, []IgnoreResult{{Path: "b.txt"}}, list)
+}
@@ -0,0 +1,270 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigScope defines the scope of a config value.
+type ConfigScope string
+
+// Supported config scopes. ConfigScopeAny reads from every scope, as git
+// does by default, and writes to the local one.
+const (
+	ConfigScopeAny      ConfigScope = ""
+	ConfigScopeLocal    ConfigScope = "local"
+	ConfigScopeGlobal   ConfigScope = "global"
+	ConfigScopeSystem   ConfigScope = "system"
+	ConfigScopeWorktree ConfigScope = "worktree"
+)
+
+// ErrConfigKeyNotFound is returned when the requested config key is not set.
+var ErrConfigKeyNotFound = errors.New("config key not found")
+
+// ConfigEntry defines a config value along with where it came from.
+type ConfigEntry struct {
+	Key   string      `json:"key"`
+	Value string      `json:"value"`
+	Scope ConfigScope `json:"scope"`
+
+	// Origin is the value's origin, as reported by `git config --show-origin`,
+	// e.g. `file:.git/config`.
+	Origin string `json:"origin"`
+}
+
+func (h *handlerImpl) AddConfig(scope ConfigScope, key, value string) error {
+	h.log.With(
+		"scope", scope,
+		"key", key,
+		"value", value,
+	).Info("Adding config value")
+
+	args := h.configArgs(scope, "--add", key, value)
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) ConfigAll(scope ConfigScope, key string) (values []string, err error) {
+	h.log.With(
+		"scope", scope,
+		"key", key,
+	).Info("Getting all config values")
+
+	args := h.configArgs(scope, "--null", "--get-all", key)
+	out, err := h.executeConfigGet(args...)
+	if err != nil {
+		return
+	}
+
+	values = strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	return
+}
+
+func (h *handlerImpl) ConfigAt(scope ConfigScope, key string) (value string, err error) {
+	return h.configTyped(scope, key, "")
+}
+
+func (h *handlerImpl) ConfigBool(scope ConfigScope, key string) (bool, error) {
+	value, err := h.configTyped(scope, key, "bool")
+	if err != nil {
+		return false, err
+	}
+
+	return value == "true", nil
+}
+
+func (h *handlerImpl) ConfigExpiryDate(scope ConfigScope, key string) (time.Time, error) {
+	value, err := h.configTyped(scope, key, "expiry-date")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ts, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Unix(ts, 0), nil
+}
+
+func (h *handlerImpl) ConfigInt(scope ConfigScope, key string) (int64, error) {
+	value, err := h.configTyped(scope, key, "int")
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (h *handlerImpl) ConfigList(scope ConfigScope, pattern string) (list []ConfigEntry, err error) {
+	h.log.With(
+		"scope", scope,
+		"pattern", pattern,
+	).Info("Listing config values")
+
+	var args []string
+	if pattern == "" {
+		args = h.configArgs(scope, "--null", "--show-origin", "--show-scope", "--list")
+	} else {
+		args = h.configArgs(scope, "--null", "--show-origin", "--show-scope", "--get-regexp", pattern)
+	}
+
+	out, err := h.executeConfigGet(args...)
+	if err != nil {
+		if errors.Is(err, ErrConfigKeyNotFound) {
+			err = nil
+		}
+		return
+	}
+
+	// Each entry is `scope NUL origin NUL key LF value NUL`, the value
+	// (and LF) being absent for keys with no value at all.
+	fields := strings.Split(strings.TrimSuffix(string(out), "\x00"), "\x00")
+	for i := 0; i+2 < len(fields); i += 3 {
+		key, value, _ := strings.Cut(fields[i+2], "\n")
+		list = append(list, ConfigEntry{
+			Key:    key,
+			Value:  value,
+			Scope:  ConfigScope(fields[i]),
+			Origin: fields[i+1],
+		})
+	}
+
+	return
+}
+
+func (h *handlerImpl) ConfigPath(scope ConfigScope, key string) (string, error) {
+	return h.configTyped(scope, key, "path")
+}
+
+func (h *handlerImpl) ConfigWithOrigin(scope ConfigScope, key string) (entry ConfigEntry, err error) {
+	h.log.With(
+		"scope", scope,
+		"key", key,
+	).Info("Getting config entry")
+
+	args := h.configArgs(scope, "--null", "--show-origin", "--show-scope", "--get", key)
+	out, err := h.executeConfigGet(args...)
+	if err != nil {
+		return
+	}
+
+	fields := strings.SplitN(strings.TrimSuffix(string(out), "\x00"), "\x00", 3)
+	if len(fields) < 3 {
+		err = fmt.Errorf("unexpected config output: %q", out)
+		return
+	}
+
+	entry = ConfigEntry{
+		Key:    key,
+		Value:  fields[2],
+		Scope:  ConfigScope(fields[0]),
+		Origin: fields[1],
+	}
+	return
+}
+
+func (h *handlerImpl) SetConfigAt(scope ConfigScope, key, value string) error {
+	h.log.With(
+		"scope", scope,
+		"key", key,
+		"value", value,
+	).Info("Setting config value")
+
+	args := h.configArgs(scope, key, value)
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) SetConfigFile(scope ConfigScope, file string) error {
+	h.log.With(
+		"scope", scope,
+		"file", file,
+	).Info("Redirecting config scope to file")
+
+	var name string
+	switch scope {
+	case ConfigScopeGlobal:
+		name = "GIT_CONFIG_GLOBAL"
+	case ConfigScopeSystem:
+		name = "GIT_CONFIG_SYSTEM"
+	default:
+		return fmt.Errorf("config scope %q cannot be redirected", scope)
+	}
+
+	h.setEnv(name, file)
+	return nil
+}
+
+func (h *handlerImpl) UnsetConfig(scope ConfigScope, key string, all ...bool) error {
+	h.log.With(
+		"scope", scope,
+		"key", key,
+		"all", all,
+	).Info("Unsetting config value")
+
+	action := "--unset"
+	if len(all) > 0 && all[0] {
+		action = "--unset-all"
+	}
+
+	args := h.configArgs(scope, action, key)
+	err := h.executeNO(args...)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 5 {
+		return fmt.Errorf("%w: %s", ErrConfigKeyNotFound, key)
+	}
+
+	return err
+}
+
+// configArgs returns the arguments for a `git config` command in the given scope.
+func (h *handlerImpl) configArgs(scope ConfigScope, in ...string) []string {
+	args := []string{"config"}
+	if scope != ConfigScopeAny {
+		args = append(args, "--"+string(scope))
+	}
+
+	return append(args, in...)
+}
+
+// configTyped returns the value of the given key, canonicalized by git
+// according to the given type, if any.
+func (h *handlerImpl) configTyped(scope ConfigScope, key, typ string) (value string, err error) {
+	h.log.With(
+		"scope", scope,
+		"key", key,
+		"type", typ,
+	).Info("Getting config value")
+
+	var args []string
+	if typ != "" {
+		args = h.configArgs(scope, "--type", typ, "--get", key)
+	} else {
+		args = h.configArgs(scope, "--get", key)
+	}
+
+	out, err := h.executeConfigGet(args...)
+	if err != nil {
+		return
+	}
+
+	value = strings.TrimSuffix(string(out), "\n")
+	return
+}
+
+// executeConfigGet runs a `git config` read command, translating the
+// exit code for missing keys into ErrConfigKeyNotFound.
+func (h *handlerImpl) executeConfigGet(args ...string) ([]byte, error) {
+	out, err := h.execute(args...)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return nil, fmt.Errorf("%w: %s", ErrConfigKeyNotFound, args[len(args)-1])
+	}
+
+	return out, err
+}
@@ -0,0 +1,141 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestConfigScopes(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	global := filepath.Join(dir, "global.gitconfig")
+	system := filepath.Join(dir, "system.gitconfig")
+
+	err := g.SetConfigFile(ConfigScopeGlobal, global)
+	assert.NoError(t, err)
+
+	err = g.SetConfigFile(ConfigScopeSystem, system)
+	assert.NoError(t, err)
+
+	err = g.SetConfigFile(ConfigScopeLocal, global)
+	assert.Error(t, err)
+
+	err = g.SetConfigAt(ConfigScopeSystem, "test.value", "system")
+	assert.NoError(t, err)
+
+	err = g.SetConfigAt(ConfigScopeGlobal, "test.value", "global")
+	assert.NoError(t, err)
+
+	value, err := g.ConfigAt(ConfigScopeAny, "test.value")
+	assert.NoError(t, err)
+	assert.Equal(t, "global", value)
+
+	err = g.SetConfigAt(ConfigScopeLocal, "test.value", "local")
+	assert.NoError(t, err)
+
+	entry, err := g.ConfigWithOrigin(ConfigScopeAny, "test.value")
+	assert.NoError(t, err)
+	assert.Equal(t, ConfigEntry{
+		Key:    "test.value",
+		Value:  "local",
+		Scope:  ConfigScopeLocal,
+		Origin: "file:.git/config",
+	}, entry)
+
+	value, err = g.ConfigAt(ConfigScopeSystem, "test.value")
+	assert.NoError(t, err)
+	assert.Equal(t, "system", value)
+
+	list, err := g.ConfigList(ConfigScopeAny, `^test\.`)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(list))
+	assert.Equal(t, ConfigScopeSystem, list[0].Scope)
+	assert.Equal(t, "file:"+system, list[0].Origin)
+	assert.Equal(t, ConfigScopeGlobal, list[1].Scope)
+	assert.Equal(t, ConfigScopeLocal, list[2].Scope)
+
+	list, err = g.ConfigList(ConfigScopeAny, `^nothing\.`)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(list))
+
+	err = g.UnsetConfig(ConfigScopeLocal, "test.value")
+	assert.NoError(t, err)
+
+	err = g.UnsetConfig(ConfigScopeLocal, "test.value")
+	assert.Equal(t, true, errors.Is(err, ErrConfigKeyNotFound))
+
+	_, err = g.ConfigAt(ConfigScopeLocal, "test.value")
+	assert.Equal(t, true, errors.Is(err, ErrConfigKeyNotFound))
+}
+
+func TestConfigMultiValued(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	for _, v := range []string{"one", "two words"} {
+		err := g.AddConfig(ConfigScopeLocal, "test.multi", v)
+		assert.NoError(t, err)
+	}
+
+	values, err := g.ConfigAll(ConfigScopeLocal, "test.multi")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"one", "two words"}, values)
+
+	err = g.UnsetConfig(ConfigScopeLocal, "test.multi")
+	assert.Error(t, err)
+
+	err = g.UnsetConfig(ConfigScopeLocal, "test.multi", true)
+	assert.NoError(t, err)
+
+	_, err = g.ConfigAll(ConfigScopeLocal, "test.multi")
+	assert.Equal(t, true, errors.Is(err, ErrConfigKeyNotFound))
+}
+
+func TestConfigTyped(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	home, err := os.UserHomeDir()
+	assert.NoError(t, err)
+
+	settings := map[string]string{
+		"test.yes":    "yes",
+		"test.off":    "off",
+		"test.size":   "2k",
+		"test.path":   "~/some/path",
+		"test.expiry": "never",
+		"test.maybe":  "maybe",
+	}
+	for k, v := range settings {
+		err = g.SetConfigAt(ConfigScopeLocal, k, v)
+		assert.NoError(t, err)
+	}
+
+	b, err := g.ConfigBool(ConfigScopeAny, "test.yes")
+	assert.NoError(t, err)
+	assert.Equal(t, true, b)
+
+	b, err = g.ConfigBool(ConfigScopeAny, "test.off")
+	assert.NoError(t, err)
+	assert.Equal(t, false, b)
+
+	_, err = g.ConfigBool(ConfigScopeAny, "test.maybe")
+	assert.Error(t, err)
+
+	i, err := g.ConfigInt(ConfigScopeAny, "test.size")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2048), i)
+
+	p, err := g.ConfigPath(ConfigScopeAny, "test.path")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "some/path"), p)
+
+	d, err := g.ConfigExpiryDate(ConfigScopeAny, "test.expiry")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), d.Unix())
+}
@@ -0,0 +1,129 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// ContributorStats defines the contributions of an author over a range of commits.
+type ContributorStats struct {
+	// Name and Email identify the author, as mapped by .mailmap.
+	Name  string `json:"name"`
+	Email string `json:"email"`
+
+	Commits int `json:"commits"`
+
+	// Added and Deleted are the number of lines changed, binary files excluded.
+	Added   int `json:"added"`
+	Deleted int `json:"deleted"`
+
+	FirstCommit time.Time `json:"firstCommit"`
+	LastCommit  time.Time `json:"lastCommit"`
+}
+
+// ContributorReport defines the contributors over a range of commits,
+// sorted by number of commits, the most active first.
+type ContributorReport []ContributorStats
+
+// Table writes the report to w as an aligned table.
+func (r ContributorReport) Table(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "AUTHOR\tCOMMITS\tADDED\tDELETED\tFIRST\tLAST")
+	for _, c := range r {
+		fmt.Fprintf(tw, "%s <%s>\t%d\t%d\t%d\t%s\t%s\n",
+			c.Name, c.Email, c.Commits, c.Added, c.Deleted,
+			c.FirstCommit.Format(time.DateOnly), c.LastCommit.Format(time.DateOnly))
+	}
+	return tw.Flush()
+}
+
+func (h *handlerImpl) Contributors(rng string) (report ContributorReport, err error) {
+	h.log.Info("Getting contributors", "range", rng)
+
+	if rng == "" {
+		rng = "HEAD"
+	}
+
+	out, err := h.execute(
+		"-c", "core.quotePath=false",
+		"log", "--use-mailmap", "--format=%x1e%aN%x1f%aE%x1f%at", "--numstat",
+		"--end-of-options", rng,
+	)
+	if err != nil {
+		return
+	}
+
+	byAuthor := map[string]*ContributorStats{}
+	for _, rec := range strings.Split(string(out), "\x1e") {
+		lines := splitLines([]byte(rec))
+		if len(lines) == 0 {
+			continue
+		}
+
+		f := strings.Split(lines[0], "\x1f")
+		if len(f) != 3 {
+			err = fmt.Errorf("unexpected log header: %q", lines[0])
+			return
+		}
+
+		var ts int64
+		if ts, err = strconv.ParseInt(f[2], 10, 64); err != nil {
+			return
+		}
+		when := time.Unix(ts, 0)
+
+		key := f[0] + "\x1f" + f[1]
+		c, ok := byAuthor[key]
+		if !ok {
+			c = &ContributorStats{Name: f[0], Email: f[1], FirstCommit: when, LastCommit: when}
+			byAuthor[key] = c
+		}
+
+		c.Commits++
+		if when.Before(c.FirstCommit) {
+			c.FirstCommit = when
+		}
+		if when.After(c.LastCommit) {
+			c.LastCommit = when
+		}
+
+		// added TAB deleted TAB path, with `-` for binary files
+		for _, l := range lines[1:] {
+			nf := strings.SplitN(l, "\t", 3)
+			if len(nf) != 3 || nf[0] == "-" {
+				continue
+			}
+
+			added, aerr := strconv.Atoi(nf[0])
+			deleted, derr := strconv.Atoi(nf[1])
+			if aerr != nil || derr != nil {
+				err = fmt.Errorf("unexpected numstat line: %q", l)
+				return
+			}
+			c.Added += added
+			c.Deleted += deleted
+		}
+	}
+
+	report = make(ContributorReport, 0, len(byAuthor))
+	for _, c := range byAuthor {
+		report = append(report, *c)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Commits != report[j].Commits {
+			return report[i].Commits > report[j].Commits
+		}
+		if report[i].Name != report[j].Name {
+			return report[i].Name < report[j].Name
+		}
+		return report[i].Email < report[j].Email
+	})
+
+	return
+}
@@ -0,0 +1,76 @@
+package git
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestContributors(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	commits := []struct {
+		author  string
+		content string
+	}{
+		{"Alice <alice@example.com>", "a\nb\nc\n"},
+		{"Bob <bob@example.com>", "a\nB\nc\n"},
+		{"alice <alice@old.example.com>", "a\nB\nc\nd\n"},
+		{"Alice <alice@example.com>", "A\nB\nc\nd\n"},
+	}
+
+	file := filepath.Join(dir, "file.txt")
+	for i, c := range commits {
+		err := os.WriteFile(file, []byte(c.content), 0644)
+		assert.NoError(t, err)
+
+		_, err = g.CommitWithOptions(CommitOptions{
+			Message: "Commit",
+			Files:   []string{file},
+			Author:  c.author,
+			Date:    start.AddDate(0, 0, i),
+		})
+		assert.NoError(t, err)
+	}
+
+	report, err := g.Contributors("")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(report))
+
+	err = os.WriteFile(filepath.Join(dir, ".mailmap"),
+		[]byte("Alice <alice@example.com> alice <alice@old.example.com>\n"), 0644)
+	assert.NoError(t, err)
+
+	report, err = g.Contributors("")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(report))
+
+	alice := report[0]
+	assert.Equal(t, "Alice", alice.Name)
+	assert.Equal(t, "alice@example.com", alice.Email)
+	assert.Equal(t, 3, alice.Commits)
+	assert.Equal(t, 5, alice.Added)
+	assert.Equal(t, 1, alice.Deleted)
+	assert.Equal(t, true, alice.FirstCommit.Equal(start))
+	assert.Equal(t, true, alice.LastCommit.Equal(start.AddDate(0, 0, 3)))
+
+	assert.Equal(t, "Bob", report[1].Name)
+	assert.Equal(t, 1, report[1].Commits)
+
+	report, err = g.Contributors("HEAD~2..HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(report))
+	assert.Equal(t, 2, report[0].Commits)
+
+	buf := &bytes.Buffer{}
+	err = report.Table(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, true, strings.Contains(buf.String(), "Alice <alice@example.com>"))
+}
@@ -0,0 +1,315 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// CredentialRequest defines the credential git asks for, as described
+// by `git help credential`.
+type CredentialRequest struct {
+	Protocol string `json:"protocol"`
+	Host     string `json:"host"`
+
+	// Path is only set if `credential.useHttpPath` is enabled.
+	Path string `json:"path,omitempty"`
+
+	// Username is set if already known, e.g. from the URL.
+	Username string `json:"username,omitempty"`
+}
+
+// Credential defines the answer to a CredentialRequest.
+type Credential struct {
+	Username string `json:"username"`
+	Password string `json:"-"`
+}
+
+// CredentialFunc defines the signature of the function providing
+// credentials to git. Returning an empty credential lets git ask
+// elsewhere, i.e. GIT_ASKPASS or the terminal.
+type CredentialFunc func(req CredentialRequest) (Credential, error)
+
+// SSHOptions defines the options used to build GIT_SSH_COMMAND.
+type SSHOptions struct {
+	// Program is the ssh command. It defaults to `ssh`.
+	Program string `json:"program,omitempty"`
+
+	// KeyFile is the private key used, instead of the agent's or the
+	// default ones.
+	KeyFile string `json:"keyFile,omitempty"`
+
+	// KnownHostsFile replaces the user's known_hosts file.
+	KnownHostsFile string `json:"knownHostsFile,omitempty"`
+
+	// StrictHostKeyChecking is one of `yes`, `no` or `accept-new`.
+	// If empty, ssh's configuration applies.
+	StrictHostKeyChecking string `json:"strictHostKeyChecking,omitempty"`
+}
+
+// EnvOptions defines the environment of the git commands run by a handler.
+type EnvOptions struct {
+	SSH *SSHOptions `json:"ssh,omitempty"`
+
+	// AskPass is the program git runs to ask for usernames and passwords.
+	AskPass string `json:"askPass,omitempty"`
+
+	// Credentials, if set, provides usernames and passwords for remote
+	// operations, replacing the configured credential helpers.
+	Credentials CredentialFunc `json:"-"`
+
+	// NoTerminalPrompt makes git, and ssh, fail instead of prompting
+	// when credentials are missing.
+	NoTerminalPrompt bool `json:"noTerminalPrompt,omitempty"`
+
+	// Env holds extra variables, in the `NAME=value` form.
+	Env []string `json:"env,omitempty"`
+
+	// IsolateEnv passes only the variables required to run git, e.g.
+	// PATH or HOME, from the process' environment, instead of all of them.
+	IsolateEnv bool `json:"isolateEnv,omitempty"`
+}
+
+// envState holds the EnvOptions of a handler.
+type envState struct {
+	mu   sync.Mutex
+	opts EnvOptions
+}
+
+// isolatedEnvVars are the variables passed from the process' environment
+// when EnvOptions.IsolateEnv is set.
+var isolatedEnvVars = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "LANG", "LC_ALL", "TMPDIR", "TZ",
+	"SSH_AUTH_SOCK",
+	// NOTE: required by Windows
+	"SYSTEMROOT", "COMSPEC", "PATHEXT", "WINDIR", "USERPROFILE", "APPDATA",
+	"LOCALAPPDATA", "TEMP", "TMP",
+}
+
+// remoteCommands are the git commands that may ask for credentials.
+var remoteCommands = []string{
+	"clone", "fetch", "ls-remote", "pull", "push", "remote", "submodule",
+}
+
+func (h *handlerImpl) SetEnvOptions(opts EnvOptions) error {
+	h.log.With(
+		"ssh", opts.SSH != nil,
+		"askpass", opts.AskPass,
+		"credentials", opts.Credentials != nil,
+		"no-terminal-prompt", opts.NoTerminalPrompt,
+		"isolate-env", opts.IsolateEnv,
+	).Info("Setting environment options")
+
+	if opts.SSH != nil {
+		switch opts.SSH.StrictHostKeyChecking {
+		case "", "yes", "no", "accept-new":
+		default:
+			return fmt.Errorf("invalid strict host key checking: %q", opts.SSH.StrictHostKeyChecking)
+		}
+	}
+
+	for _, e := range opts.Env {
+		if name, _, found := strings.Cut(e, "="); !found || name == "" {
+			return fmt.Errorf("invalid environment variable: %q", e)
+		}
+	}
+
+	h.envOpts.mu.Lock()
+	defer h.envOpts.mu.Unlock()
+
+	h.envOpts.opts = opts
+	return nil
+}
+
+// environ returns the environment for a git command, with the given
+// variables added, or nil if the process' one can be used as is.
+func (h *handlerImpl) environ(extra []string) []string {
+	opts := h.envOptions()
+
+	var env []string
+	if opts.SSH != nil {
+		env = append(env, "GIT_SSH_COMMAND="+opts.SSH.command(opts.NoTerminalPrompt))
+	}
+	if opts.AskPass != "" {
+		env = append(env, "GIT_ASKPASS="+opts.AskPass)
+	}
+	if opts.NoTerminalPrompt {
+		env = append(env, "GIT_TERMINAL_PROMPT=0")
+	}
+	env = append(env, opts.Env...)
+	env = append(env, h.env...)
+	env = append(env, extra...)
+
+	if !opts.IsolateEnv {
+		if len(env) == 0 {
+			return nil
+		}
+		return append(os.Environ(), env...)
+	}
+
+	var base []string
+	for _, e := range os.Environ() {
+		name, _, _ := strings.Cut(e, "=")
+		if slices.Contains(isolatedEnvVars, strings.ToUpper(name)) {
+			base = append(base, e)
+		}
+	}
+
+	// NOTE: an empty, non-nil, environment keeps exec from inheriting
+	// the process' one
+	return append(append([]string{}, base...), env...)
+}
+
+// envOptions returns the handler's EnvOptions.
+func (h *handlerImpl) envOptions() EnvOptions {
+	h.envOpts.mu.Lock()
+	defer h.envOpts.mu.Unlock()
+
+	return h.envOpts.opts
+}
+
+// command returns the value of GIT_SSH_COMMAND.
+func (o *SSHOptions) command(batchMode bool) string {
+	program := o.Program
+	if program == "" {
+		program = "ssh"
+	}
+
+	args := []string{program}
+	if o.KeyFile != "" {
+		args = append(args, "-i", shellQuote(o.KeyFile), "-o", "IdentitiesOnly=yes")
+	}
+	if o.KnownHostsFile != "" {
+		args = append(args, "-o", "UserKnownHostsFile="+shellQuote(o.KnownHostsFile))
+	}
+	if o.StrictHostKeyChecking != "" {
+		args = append(args, "-o", "StrictHostKeyChecking="+o.StrictHostKeyChecking)
+	}
+	if batchMode {
+		args = append(args, "-o", "BatchMode=yes")
+	}
+
+	return strings.Join(args, " ")
+}
+
+// credentialArgs returns the global options making the given git
+// command ask the handler's CredentialFunc, if any, for credentials,
+// and the function to call once the command is done.
+func (h *handlerImpl) credentialArgs(in []string) (args []string, stop func(), err error) {
+	stop = func() {}
+
+	fn := h.envOptions().Credentials
+	if name, _ := commandName(in); fn == nil || !slices.Contains(remoteCommands, name) {
+		return
+	}
+
+	helper, stop, err := serveCredentials(fn, h.log)
+	if err != nil {
+		return
+	}
+
+	// NOTE: the empty value resets the list of configured helpers
+	args = []string{"-c", "credential.helper=", "-c", "credential.helper=" + helper}
+	return
+}
+
+// serveCredentials answers the requests of `git credential-cache` with
+// the given function, and returns the helper to configure.
+func serveCredentials(fn CredentialFunc, log *slog.Logger) (helper string, stop func(), err error) {
+	dir, err := os.MkdirTemp("", "git-credentials-")
+	if err != nil {
+		return
+	}
+
+	socket := filepath.Join(dir, "socket")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		os.RemoveAll(dir)
+		return
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				if err := answerCredential(conn, fn); err != nil {
+					log.Warn("Failed to answer credential request", "error", err)
+				}
+			}()
+		}
+	}()
+
+	helper = "cache --socket " + shellQuote(socket)
+	stop = func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+	return
+}
+
+// answerCredential answers a single `git credential-cache` request.
+func answerCredential(conn net.Conn, fn CredentialFunc) error {
+	// NOTE: the client closes its side once the request is written
+	b, err := io.ReadAll(conn)
+	if err != nil {
+		return err
+	}
+
+	var action string
+	var req CredentialRequest
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		key, value, _ := strings.Cut(sc.Text(), "=")
+		switch key {
+		case "action":
+			action = value
+		case "protocol":
+			req.Protocol = value
+		case "host":
+			req.Host = value
+		case "path":
+			req.Path = value
+		case "username":
+			req.Username = value
+		}
+	}
+
+	// NOTE: `store` and `erase` are ignored, since fn is the only source
+	if action != "get" {
+		return nil
+	}
+
+	cred, err := fn(req)
+	if err != nil {
+		return err
+	}
+
+	if cred.Username == "" && cred.Password == "" {
+		return nil
+	}
+
+	if strings.ContainsAny(cred.Username+cred.Password, "\n\x00") {
+		return fmt.Errorf("invalid credential for %s", req.Host)
+	}
+
+	_, err = fmt.Fprintf(conn, "username=%s\npassword=%s\n", cred.Username, cred.Password)
+	return err
+}
+
+// shellQuote quotes the given string for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
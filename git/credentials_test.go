@@ -0,0 +1,182 @@
+package git
+
+import (
+	"net/http"
+	"net/http/cgi"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests"
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestSetEnvOptions(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	h := g.(*handlerImpl)
+
+	t.Run("Invalid", func(t *testing.T) {
+		err := g.SetEnvOptions(EnvOptions{SSH: &SSHOptions{StrictHostKeyChecking: "maybe"}})
+		assert.Error(t, err)
+
+		err = g.SetEnvOptions(EnvOptions{Env: []string{"NO_VALUE"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("Isolate", func(t *testing.T) {
+		t.Setenv("BNP_TEST_INHERITED", "1")
+
+		err := g.SetEnvOptions(EnvOptions{
+			Env:              []string{"BNP_TEST_EXTRA=2"},
+			NoTerminalPrompt: true,
+		})
+		assert.NoError(t, err)
+
+		out, err := h.execute("-c", "alias.printenv=!env", "printenv")
+		assert.NoError(t, err)
+		assert.Equal(t, true, strings.Contains(string(out), "BNP_TEST_INHERITED=1"))
+		assert.Equal(t, true, strings.Contains(string(out), "BNP_TEST_EXTRA=2"))
+		assert.Equal(t, true, strings.Contains(string(out), "GIT_TERMINAL_PROMPT=0"))
+
+		err = g.SetEnvOptions(EnvOptions{
+			Env:        []string{"BNP_TEST_EXTRA=2"},
+			IsolateEnv: true,
+		})
+		assert.NoError(t, err)
+		defer g.SetEnvOptions(EnvOptions{})
+
+		out, err = h.execute("-c", "alias.printenv=!env", "printenv")
+		assert.NoError(t, err)
+		assert.Equal(t, false, strings.Contains(string(out), "BNP_TEST_INHERITED"))
+		assert.Equal(t, true, strings.Contains(string(out), "BNP_TEST_EXTRA=2"))
+	})
+
+	t.Run("SSH", func(t *testing.T) {
+		tmp := tests.NewTempDir(t)
+		defer os.RemoveAll(tmp)
+
+		argsFile := filepath.Join(tmp, "args")
+		ssh := filepath.Join(tmp, "fake-ssh")
+		err := os.WriteFile(ssh, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\" > "+shellQuote(argsFile)+"\nexit 1\n"), 0755)
+		assert.NoError(t, err)
+
+		key := filepath.Join(tmp, "my key")
+		err = g.SetEnvOptions(EnvOptions{
+			SSH: &SSHOptions{
+				Program:               ssh,
+				KeyFile:               key,
+				KnownHostsFile:        filepath.Join(tmp, "known_hosts"),
+				StrictHostKeyChecking: "yes",
+			},
+			NoTerminalPrompt: true,
+		})
+		assert.NoError(t, err)
+		defer g.SetEnvOptions(EnvOptions{})
+
+		err = g.SetRemote("origin", "ssh://git@example.invalid/repo.git")
+		assert.NoError(t, err)
+
+		err = g.Fetch("origin")
+		assert.Error(t, err)
+
+		b, err := os.ReadFile(argsFile)
+		assert.NoError(t, err)
+
+		args := splitLines(b)
+		assert.Equal(t, true, strings.Contains(strings.Join(args, " "), "-i "+key))
+		for _, want := range []string{
+			"IdentitiesOnly=yes",
+			"UserKnownHostsFile=" + filepath.Join(tmp, "known_hosts"),
+			"StrictHostKeyChecking=yes",
+			"BatchMode=yes",
+		} {
+			found := false
+			for _, a := range args {
+				found = found || a == want
+			}
+			assert.Equal(t, true, found)
+		}
+	})
+}
+
+func TestCredentials(t *testing.T) {
+	src, srcDir := newTestRepo(t, "main")
+	defer os.RemoveAll(srcDir)
+
+	file := filepath.Join(srcDir, "file.txt")
+	err := os.WriteFile(file, []byte("content"), 0644)
+	assert.NoError(t, err)
+	err = src.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	serverDir := tests.NewTempDir(t)
+	defer os.RemoveAll(serverDir)
+
+	_, err = src.(*handlerImpl).execute("clone", "--bare", "--quiet", srcDir, filepath.Join(serverDir, "repo.git"))
+	assert.NoError(t, err)
+
+	gitPath, err := exec.LookPath("git")
+	assert.NoError(t, err)
+
+	backend := &cgi.Handler{
+		Path: gitPath,
+		Args: []string{"http-backend"},
+		Env:  []string{"GIT_PROJECT_ROOT=" + serverDir, "GIT_HTTP_EXPORT_ALL=1"},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "bot" || pass != "s3cret" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="test"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		backend.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	assert.NoError(t, err)
+
+	clone := func(t *testing.T, password string) (reqs []CredentialRequest, err error) {
+		dir := tests.NewTempDir(t)
+		defer os.RemoveAll(dir)
+
+		g, err := NewHandler(dir)
+		assert.NoError(t, err)
+
+		var mu sync.Mutex
+		err = g.SetEnvOptions(EnvOptions{
+			Credentials: func(req CredentialRequest) (Credential, error) {
+				mu.Lock()
+				defer mu.Unlock()
+				reqs = append(reqs, req)
+				return Credential{Username: "bot", Password: password}, nil
+			},
+			NoTerminalPrompt: true,
+		})
+		assert.NoError(t, err)
+
+		err = g.Clone(server.URL+"/repo.git", CloneOptions{})
+		return
+	}
+
+	t.Run("Valid", func(t *testing.T) {
+		reqs, err := clone(t, "s3cret")
+		assert.NoError(t, err)
+		assert.Equal(t, true, len(reqs) > 0)
+		assert.Equal(t, "http", reqs[0].Protocol)
+		assert.Equal(t, u.Host, reqs[0].Host)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := clone(t, "wrong")
+		assert.Error(t, err)
+	})
+}
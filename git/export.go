@@ -0,0 +1,124 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ArchiveFormat defines the format of an archive.
+type ArchiveFormat string
+
+// Supported archive formats.
+const (
+	ArchiveTar   ArchiveFormat = "tar"
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// ArchiveOptions defines the options supported by Archive.
+type ArchiveOptions struct {
+	// Rev is the revision to archive. It defaults to HEAD.
+	Rev string `json:"rev,omitempty"`
+
+	// Format defaults to ArchiveTar.
+	Format ArchiveFormat `json:"format,omitempty"`
+
+	// Prefix is prepended to every path in the archive, e.g. `project-1.0/`.
+	Prefix string `json:"prefix,omitempty"`
+
+	// Pathspecs, if given, restricts the archive to the matching paths.
+	Pathspecs []string `json:"pathspecs,omitempty"`
+}
+
+// BundleHead defines a reference contained in a bundle.
+type BundleHead struct {
+	Hash string `json:"hash"`
+	Ref  string `json:"ref"`
+}
+
+func (h *handlerImpl) Archive(w io.Writer, opts ArchiveOptions) error {
+	h.log.With(
+		"rev", opts.Rev,
+		"format", opts.Format,
+		"prefix", opts.Prefix,
+		"pathspecs", opts.Pathspecs,
+	).Info("Archiving tree")
+
+	if opts.Rev == "" {
+		opts.Rev = "HEAD"
+	}
+
+	if opts.Format == "" {
+		opts.Format = ArchiveTar
+	}
+
+	switch opts.Format {
+	case ArchiveTar, ArchiveTarGz, ArchiveZip:
+	default:
+		return fmt.Errorf("unsupported archive format: %q", opts.Format)
+	}
+
+	args := []string{"archive", "--format", string(opts.Format)}
+	if opts.Prefix != "" {
+		args = append(args, "--prefix", opts.Prefix)
+	}
+	args = append(args, "--end-of-options", opts.Rev)
+	args = append(args, opts.Pathspecs...)
+
+	return h.executeTo(w, args...)
+}
+
+func (h *handlerImpl) ArchiveLatestTag(w io.Writer, opts ArchiveOptions) (tag string, err error) {
+	h.log.Info("Archiving latest tag")
+
+	if tag, err = h.LatestTag(true); err != nil {
+		return
+	}
+
+	opts.Rev = tag
+	err = h.Archive(w, opts)
+	return
+}
+
+func (h *handlerImpl) CreateBundle(file string, revs ...string) error {
+	h.log.With(
+		"file", file,
+		"revs", revs,
+	).Info("Creating bundle")
+
+	args := []string{"bundle", "create", file}
+	if len(revs) > 0 {
+		args = append(args, revs...)
+	} else {
+		args = append(args, "--all")
+	}
+
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) ListBundleHeads(file string) (list []BundleHead, err error) {
+	h.log.Info("Listing bundle heads", "file", file)
+
+	out, err := h.execute("bundle", "list-heads", file)
+	if err != nil {
+		return
+	}
+
+	for _, l := range splitLines(out) {
+		hash, ref, found := strings.Cut(l, " ")
+		if !found {
+			err = fmt.Errorf("unexpected bundle head: %q", l)
+			return
+		}
+		list = append(list, BundleHead{Hash: hash, Ref: ref})
+	}
+
+	return
+}
+
+func (h *handlerImpl) VerifyBundle(file string) error {
+	h.log.Info("Verifying bundle", "file", file)
+
+	return h.executeNO("bundle", "verify", "--quiet", file)
+}
@@ -0,0 +1,126 @@
+package git
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests"
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestArchive(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".gitattributes": "secret.txt export-ignore\n",
+		"file.txt":       "test",
+		"secret.txt":     "secret",
+	}
+	for f, content := range files {
+		err := os.WriteFile(filepath.Join(dir, f), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	err := g.CommitFiles([]string{".gitattributes", "file.txt", "secret.txt"}, "Initial commit")
+	assert.NoError(t, err)
+
+	err = g.NewTag("v0.1.0", "Initial release")
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, "file.txt"), []byte("changed"), 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{"file.txt"}, "Some change")
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	tag, err := g.ArchiveLatestTag(buf, ArchiveOptions{Prefix: "project/"})
+	assert.NoError(t, err)
+	assert.Equal(t, "v0.1.0", tag)
+
+	contents := map[string]string{}
+	tr := tar.NewReader(buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+
+		b, err := io.ReadAll(tr)
+		assert.NoError(t, err)
+		contents[hdr.Name] = string(b)
+	}
+
+	assert.Equal(t, map[string]string{
+		"project/":               "",
+		"project/.gitattributes": files[".gitattributes"],
+		"project/file.txt":       "test",
+	}, contents)
+
+	buf.Reset()
+	err = g.Archive(buf, ArchiveOptions{Format: ArchiveZip, Pathspecs: []string{"file.txt"}})
+	assert.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(zr.File))
+	assert.Equal(t, "file.txt", zr.File[0].Name)
+
+	err = g.Archive(buf, ArchiveOptions{Format: "rar"})
+	assert.Error(t, err)
+}
+
+func TestBundle(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := "file.txt"
+	err := os.WriteFile(filepath.Join(dir, file), []byte{}, 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	hash, err := g.LatestHash(true)
+	assert.NoError(t, err)
+
+	out := tests.NewTempDir(t)
+	defer os.RemoveAll(out)
+
+	bundle := filepath.Join(out, "repo.bundle")
+	err = g.CreateBundle(bundle, "main")
+	assert.NoError(t, err)
+
+	err = g.VerifyBundle(bundle)
+	assert.NoError(t, err)
+
+	heads, err := g.ListBundleHeads(bundle)
+	assert.NoError(t, err)
+	assert.Equal(t, []BundleHead{{Hash: hash, Ref: "refs/heads/main"}}, heads)
+
+	clone := filepath.Join(out, "clone")
+	_, err = exec.Command("git", "clone", "--quiet", "--branch", "main", bundle, clone).CombinedOutput()
+	assert.NoError(t, err)
+
+	g2, err := NewHandler(clone)
+	assert.NoError(t, err)
+
+	actual, err := g2.LatestHash(true)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, actual)
+
+	err = g.VerifyBundle(filepath.Join(out, "missing.bundle"))
+	assert.Error(t, err)
+}
@@ -1,6 +1,9 @@
 package git
 
 import (
+	"context"
+	"errors"
+	"io"
 	"os/exec"
 	"time"
 )
@@ -10,30 +13,140 @@ type RestoreCwdFunc func() error
 
 // Handler provides a handler to git's command line.
 type Handler interface {
+	// AbortMailbox aborts the mailbox application in progress, restoring the original branch
+	AbortMailbox() error
+
+	// AddConfig adds a value to a multi-valued config key in the given scope
+	AddConfig(scope ConfigScope, key, value string) error
+
+	// AddNote attaches a note to the given revision in the given notes reference
+	AddNote(notesRef, rev, msg string, force ...bool) error
+
+	// AddObserver registers a function to be called after every git invocation
+	AddObserver(obs CommandObserver)
+
 	// AddToStaging adds the given files to staging
 	AddToStaging(files []string) (err error)
 
+	// AheadBehind returns the number of commits in rev not in upstream, and vice versa
+	AheadBehind(rev, upstream string) (ahead, behind int, err error)
+
+	// ApplyMailbox applies the patches in the given mbox files as commits.
+	// A *PatchConflictError is returned if a patch does not apply
+	ApplyMailbox(files []string, threeWay ...bool) error
+
+	// ApplyPatch applies the given patch file to the working tree
+	ApplyPatch(file string, opts ApplyOptions) error
+
+	// ApplyStash applies the stash entry with the given index, keeping it in the stash
+	ApplyStash(index int, restoreIndex ...bool) error
+
+	// Archive writes an archive of the given revision to w, honouring the export-ignore attribute
+	Archive(w io.Writer, opts ArchiveOptions) error
+
+	// ArchiveLatestTag archives the latest tag and returns it; opts.Rev is ignored
+	ArchiveLatestTag(w io.Writer, opts ArchiveOptions) (tag string, err error)
+
+	// Bisect finds the first bad commit, calling test for every revision to check,
+	// and resets the bisect state when done, on error or when ctx is done
+	Bisect(ctx context.Context, opts BisectOptions, test BisectFunc) (result BisectResult, err error)
+
+	// BisectLog returns the log of the bisection in progress
+	BisectLog() (string, error)
+
+	// BisectMark marks the given revisions, or the current one, with the given term.
+	// A *BisectInconclusiveError is returned if only skipped commits are left
+	BisectMark(term BisectTerm, revs ...string) (step BisectStep, err error)
+
+	// BisectReset ends the bisection in progress, restoring the original branch
+	BisectReset() error
+
+	// BisectRun is like Bisect, running the given command for every revision to check,
+	// with the same exit code semantics as `git bisect run`
+	BisectRun(ctx context.Context, opts BisectOptions, name string, args ...string) (BisectResult, error)
+
+	// BisectStart starts a bisection between the given good and bad revisions
+	BisectStart(opts BisectOptions) (step BisectStep, err error)
+
 	// Branch returns the active branch
 	Branch() (name string, err error)
 
 	// Branches returns the list of branches
 	Branches(all ...bool) (list []string, err error)
 
+	// CheckAttr returns the values of the given gitattributes, or all the set ones, for each of the given paths
+	CheckAttr(paths []string, attrs ...string) ([]PathAttributes, error)
+
+	// CheckIgnore reports, for each of the given paths, whether it is ignored and which pattern matched it
+	CheckIgnore(paths []string) ([]IgnoreResult, error)
+
+	// CheckPolicy checks the staged files against the given policy, or the repository's one if nil
+	CheckPolicy(policy *Policy) (report PolicyReport, err error)
+
 	// CheckoutBranch checks out the given branch
 	CheckoutBranch(name string) error
 
 	// CheckoutNewBranch creates the given branch and checks it out
 	CheckoutNewBranch(name string) error
 
+	// Clean removes untracked files from the working tree and returns the removed paths
+	Clean(opts CleanOptions) (paths []string, err error)
+
+	// Clone clones the given repository into the root directory, which must be empty
+	Clone(url string, opts CloneOptions) error
+
+	// Close releases the resources held by the handler, e.g. open files
+	Close() error
+
 	// Commit commits files in staging with the given message
 	Commit(msg string) (err error)
 
 	// CommitFiles adds the given files to staging and commits them with the given message
 	CommitFiles(files []string, msg string) (err error)
 
+	// CommitWithOptions commits according to the given options and returns the new commit's hash
+	CommitWithOptions(opts CommitOptions) (hash string, err error)
+
 	// Config returns the current config value
 	Config(key string) (value string, err error)
 
+	// ConfigAll returns all the values of a multi-valued config key in the given scope
+	ConfigAll(scope ConfigScope, key string) (values []string, err error)
+
+	// ConfigAt returns the config value for the given key in the given scope
+	ConfigAt(scope ConfigScope, key string) (value string, err error)
+
+	// ConfigBool returns the config value for the given key as a boolean, as interpreted by git
+	ConfigBool(scope ConfigScope, key string) (bool, error)
+
+	// ConfigExpiryDate returns the config value for the given key as an expiry date (e.g. `2.weeks.ago`)
+	ConfigExpiryDate(scope ConfigScope, key string) (time.Time, error)
+
+	// ConfigInt returns the config value for the given key as an integer, honouring the k, m and g suffixes
+	ConfigInt(scope ConfigScope, key string) (int64, error)
+
+	// ConfigList returns the config entries whose key matches the given regular expression, or all of them
+	ConfigList(scope ConfigScope, pattern string) (list []ConfigEntry, err error)
+
+	// ConfigPath returns the config value for the given key as a path, with `~` expanded
+	ConfigPath(scope ConfigScope, key string) (string, error)
+
+	// ConfigWithOrigin returns the config entry for the given key, including its scope and origin
+	ConfigWithOrigin(scope ConfigScope, key string) (entry ConfigEntry, err error)
+
+	// ContinueMailbox resumes the mailbox application in progress, once conflicts are resolved
+	ContinueMailbox() error
+
+	// Contributors returns the per-author statistics over the given range of commits,
+	// or HEAD, honouring .mailmap
+	Contributors(rng string) (report ContributorReport, err error)
+
+	// CountObjects returns the loose and packed object statistics
+	CountObjects() (ObjectCounts, error)
+
+	// CreateBundle creates a bundle file with the given revisions, or all references
+	CreateBundle(file string, revs ...string) error
+
 	// DeleteBranch removes the given branch
 	DeleteBranch(name string, force ...bool) error
 
@@ -43,27 +156,81 @@ type Handler interface {
 	// DiffUpstream compares current branch to the given upstream one
 	DiffUpstream(remote, branch string) (differs bool, diff string, err error)
 
-	// DropStash
+	// DropStash drops the most recent stash entry, or all of them
 	DropStash(all ...bool) error
 
+	// DropStashAt drops the stash entry with the given index
+	DropStashAt(index int) error
+
 	// Fetch brings the latest changes for the given remote
 	Fetch(remote string) (err error)
 
+	// FetchNotes fetches the given notes reference from the given remote
+	FetchNotes(remote, notesRef string) error
+
+	// FileAt returns the contents of the given file, relative to the repository root,
+	// at the given revision, or HEAD
+	FileAt(rev, path string) ([]byte, error)
+
 	// FileChanged checks if a file changed and should be added to staging
 	FileChanged(file string) bool
 
+	// FileHistory returns the commits that changed the given file, following renames,
+	// the most recent first. If maxCount is greater than 0, it limits the number of entries
+	FileHistory(path string, maxCount int) (list []FileHistoryEntry, err error)
+
+	// IsAncestor checks if ancestor is an ancestor of rev
+	IsAncestor(ancestor, rev string) (bool, error)
+
+	// FormatPatch writes a patch per commit in the given range to outputDir and returns their paths
+	FormatPatch(rng, outputDir string) (files []string, err error)
+
+	// FormatPatchTo writes the patches for the commits in the given range to w, in mbox format
+	FormatPatchTo(w io.Writer, rng string) error
+
+	// Fsck checks the integrity of the repository and returns its findings, reporting unreachable objects if requested
+	Fsck(unreachable ...bool) ([]FsckFinding, error)
+
+	// GC cleans up and optimizes the repository
+	GC(opts GCOptions) error
+
+	// Health returns a report combining object counts, packs, fsck findings, stale locks and shallow state
+	Health() (HealthReport, error)
+
 	// Init git-initializes the root directory
 	Init(initialBranch string) error
 
-	// LatestHash Returns the latest tag for the git repo related to the working directory
+	// InitWithOptions git-initializes the root directory with the given options, scaffolding it if requested
+	InitWithOptions(opts InitOptions) error
+
+	// LatestHash returns the hash of HEAD for the git repo related to the working directory.
+	// Since HEAD is local, nothing is fetched and noFetch is kept only for compatibility
 	LatestHash(noFetch ...bool) (hash string, err error)
 
 	// LatestTag Returns the latest tag for the git repo related to the working directory
 	LatestTag(noFetch ...bool) (tag string, err error)
 
+	// ListBundleHeads returns the references contained in the given bundle file
+	ListBundleHeads(file string) ([]BundleHead, error)
+
+	// ListNotes returns the notes in the given notes reference
+	ListNotes(notesRef string) ([]NoteEntry, error)
+
 	// Log Returns log entries
 	Log(maxCount int) ([]LogEntry, error)
 
+	// LogWithNotes is like Log, including the notes from the given notes reference
+	LogWithNotes(maxCount int, notesRef string) ([]LogEntry, error)
+
+	// LostCommits returns the commits that are no longer reachable from any reference, most recent first
+	LostCommits() ([]LogEntry, error)
+
+	// MaterializedPaths returns the tracked paths present in the working tree, i.e., within the sparse checkout
+	MaterializedPaths() ([]string, error)
+
+	// MergeBase returns the best common ancestor of the given revisions
+	MergeBase(revs ...string) (hash string, err error)
+
 	// MergeStash merges remote changes, preserving ours
 	MergeStash(remote, branch, commitMsg string) error
 
@@ -79,12 +246,27 @@ type Handler interface {
 	// PopStash pops the most recent stash
 	PopStash(msg string) error
 
+	// PopStashAt pops the stash entry with the given index
+	PopStashAt(index int) error
+
+	// Prune removes unreachable loose objects older than the given expiry (e.g. `2.weeks.ago`), or git's default
+	Prune(expire string) error
+
 	// Pull updates tree with remote changes
 	Pull(remote, branch string, noCommit ...bool) error
 
 	// Push sends branch changes to remote
 	Push(remote, branch string) error
 
+	// PushNotes pushes the given notes reference to the given remote
+	PushNotes(remote, notesRef string) error
+
+	// RecoverCommit creates a new branch pointing to the given, possibly lost, commit
+	RecoverCommit(hash, branch string) error
+
+	// Reflog returns the reflog entries for the given reference (HEAD by default), most recent first
+	Reflog(ref string) ([]ReflogEntry, error)
+
 	// Remotes returns the list of remotes set for the repository
 	Remotes() (list map[string]string, err error)
 
@@ -94,29 +276,228 @@ type Handler interface {
 	// RemoveFromStaging removes the given files from the stagin area
 	RemoveFromStaging(files []string, ignoreErrors ...bool) (err error)
 
+	// RemoveNote removes the note attached to the given revision in the given notes reference
+	RemoveNote(notesRef, rev string) error
+
+	// Repack packs loose objects, or all objects into a single pack, if requested
+	Repack(all ...bool) error
+
+	// Reset resets the current branch to the given revision and returns the paths whose changes were discarded,
+	// which are none for ResetSoft
+	Reset(mode ResetMode, rev string) (paths []string, err error)
+
+	// ResolveRev returns the hash of the commit the given revision points to
+	ResolveRev(rev string) (hash string, err error)
+
+	// Restore restores the given paths and returns the ones actually restored
+	Restore(opts RestoreOptions) (paths []string, err error)
+
+	// RevListCount returns the number of commits reachable from to but not from from
+	RevListCount(from, to string) (int, error)
+
+	// Revert creates commits reverting the given ones
+	Revert(commits []string, noCommit ...bool) error
+
+	// Scaffold writes the files and hooks of a template set, committing the former
+	Scaffold(opts ScaffoldOptions) error
+
 	// SetUpstreamBranchTo implements the Handler interface
 	SetUpstreamBranchTo(remote, branch string) error
 
 	// SetConfig sets a config value
 	SetConfig(key, value string) error
 
+	// SetConfigAt sets a config value in the given scope
+	SetConfigAt(scope ConfigScope, key, value string) error
+
+	// SetAuditFile appends every git invocation to the given file, as JSON lines, or stops doing so if empty
+	SetAuditFile(file string) error
+
+	// SetConfigFile redirects the global or system config scope to the given file
+	SetConfigFile(scope ConfigScope, file string) error
+
+	// SetDryRun enables or disables recording mutating git invocations without executing them.
+	// Methods making such invocations return ErrDryRun
+	SetDryRun(dryRun bool)
+
+	// SetEnvOptions sets the environment, e.g. credentials or SSH options, of the git commands run
+	SetEnvOptions(opts EnvOptions) error
+
 	// SetRemote adds remote or sets URL for an existing remote
 	SetRemote(name, url string) error
 
+	// ShortHash returns the unique abbreviation of the given revision's hash, with the given minimum length
+	ShortHash(rev string, length ...int) (hash string, err error)
+
+	// ShowNote returns the note attached to the given revision in the given notes reference
+	ShowNote(notesRef, rev string) (note string, err error)
+
+	// ShowStash returns the files changed by the stash entry with the given index, along with its diff
+	ShowStash(index int) (files []string, diff string, err error)
+
+	// SparseCheckoutAdd adds the given directories to the sparse checkout
+	SparseCheckoutAdd(dirs ...string) error
+
+	// SparseCheckoutDisable restores a full working tree
+	SparseCheckoutDisable() error
+
+	// SparseCheckoutInit enables sparse checkout, in cone mode if requested, keeping only top-level files
+	SparseCheckoutInit(cone bool) error
+
+	// SparseCheckoutList returns the directories (or patterns, if not in cone mode) in the sparse checkout
+	SparseCheckoutList() ([]string, error)
+
+	// SparseCheckoutSet replaces the sparse checkout with the given directories
+	SparseCheckoutSet(dirs ...string) error
+
 	// Stash stashes local changes
 	Stash(msg string, untracked ...bool) (StashEntry, error)
 
+	// StashBranch creates and checks out a new branch from the stash entry with the given index
+	StashBranch(name string, index int) error
+
 	// StashList returns the list of stash entries
 	StashList() ([]StashEntry, error)
 
+	// StashWithOptions stashes local changes according to the given options
+	StashWithOptions(opts StashOptions) (StashEntry, error)
+
 	// Status reports the current status of the working tree
 	Status() (staged, unstaged, untracked []string, err error)
 
+	// SymbolicRef returns the reference the given symbolic one (e.g. HEAD) points to
+	SymbolicRef(name string) (ref string, err error)
+
 	// TopLevel returns the root directory
 	TopLevel() string
 
+	// UnsetConfig removes the given key from the given scope, or all of its values
+	UnsetConfig(scope ConfigScope, key string, all ...bool) error
+
 	// Unstage removes the given files from staging
 	Unstage(files []string) error
+
+	// VerifyBundle checks that the given bundle file is valid and applies to the repository
+	VerifyBundle(file string) error
+
+	// Watch polls the repository until ctx is done, and reports its changes,
+	// debounced, on the returned channel, which is closed when done
+	Watch(ctx context.Context, opts WatchOptions) (<-chan WatchEvent, error)
+
+	// WriteCommitGraph writes the commit-graph file for all reachable commits
+	WriteCommitGraph() error
+}
+
+// CleanOptions defines the options supported by Clean.
+type CleanOptions struct {
+	// DryRun only lists the paths that would be removed.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	// Directories also removes untracked directories.
+	Directories bool `json:"directories,omitempty"`
+
+	// Ignored also removes files ignored by git.
+	Ignored bool `json:"ignored,omitempty"`
+
+	// OnlyIgnored removes only the files ignored by git.
+	OnlyIgnored bool `json:"onlyIgnored,omitempty"`
+
+	// Pathspecs, if given, restricts the clean up to the matching paths.
+	Pathspecs []string `json:"pathspecs,omitempty"`
+}
+
+// CloneOptions defines the options supported by Clone.
+type CloneOptions struct {
+	Branch string `json:"branch,omitempty"`
+
+	// Origin names the remote to clone from. It defaults to `origin`.
+	Origin string `json:"origin,omitempty"`
+
+	// Filter is a partial clone filter, e.g. `blob:none` or `tree:0`.
+	Filter string `json:"filter,omitempty"`
+
+	// Sparse initializes a sparse checkout with only the top-level files.
+	Sparse bool `json:"sparse,omitempty"`
+
+	NoCheckout bool `json:"noCheckout,omitempty"`
+
+	// Depth, if greater than 0, creates a shallow clone.
+	Depth int `json:"depth,omitempty"`
+}
+
+// CommitOptions defines the options supported by CommitWithOptions.
+type CommitOptions struct {
+	// Message is the commit message. It can be empty when amending,
+	// in which case the previous message is kept.
+	Message string `json:"message"`
+
+	// Files, if given, are added to staging before committing.
+	Files []string `json:"files,omitempty"`
+
+	// Author overrides the commit author, in the `Name <email>` form.
+	Author string `json:"author,omitempty"`
+
+	// Date, if not zero, is used for both the author and committer dates.
+	Date time.Time `json:"date,omitempty"`
+
+	// Amend replaces the tip of the current branch.
+	Amend bool `json:"amend,omitempty"`
+
+	// AllowEmpty allows a commit without changes.
+	AllowEmpty bool `json:"allowEmpty,omitempty"`
+
+	// SignOff adds a Signed-off-by trailer for the committer.
+	SignOff bool `json:"signOff,omitempty"`
+
+	// Trailers are appended to the commit message, in the given order.
+	Trailers []Trailer `json:"trailers,omitempty"`
+
+	// Policy, if set, is checked against the staged files, and the
+	// commit is aborted with ErrPolicyViolation if it is not met.
+	Policy *Policy `json:"policy,omitempty"`
+}
+
+// Trailer defines a commit message trailer, e.g. `Co-authored-by: Name <email>`.
+type Trailer struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (t Trailer) String() string {
+	return t.Key + ": " + t.Value
+}
+
+// ObjectFormat defines the hash algorithm of a repository.
+type ObjectFormat string
+
+// Supported object formats.
+const (
+	ObjectFormatSHA1   ObjectFormat = "sha1"
+	ObjectFormatSHA256 ObjectFormat = "sha256"
+)
+
+// InitOptions defines the options supported by InitWithOptions.
+type InitOptions struct {
+	InitialBranch string `json:"initialBranch,omitempty"`
+
+	Bare bool `json:"bare,omitempty"`
+
+	// SeparateGitDir places the repository in the given directory, relative
+	// to the root one, leaving a `.git` file pointing to it in the latter.
+	SeparateGitDir string `json:"separateGitDir,omitempty"`
+
+	// Template is the directory whose files are copied to the new
+	// repository, instead of the default templates.
+	Template string `json:"template,omitempty"`
+
+	// Shared sets the repository permissions, as defined by git-init(1),
+	// e.g. `group`, `all` or `0640`.
+	Shared string `json:"shared,omitempty"`
+
+	ObjectFormat ObjectFormat `json:"objectFormat,omitempty"`
+
+	// Scaffold, if set, scaffolds the new repository.
+	Scaffold *ScaffoldOptions `json:"scaffold,omitempty"`
 }
 
 type LogEntry struct {
@@ -126,14 +507,72 @@ type LogEntry struct {
 	Email     string    `json:"email"`
 	Subject   string    `json:"subject"`
 	Body      string    `json:"body"`
+	Notes     string    `json:"notes,omitempty"`
 }
 
+// ResetMode defines the mode used by Reset.
+type ResetMode string
+
+// Supported reset modes, as defined by git-reset(1).
+const (
+	ResetSoft  ResetMode = "soft"
+	ResetMixed ResetMode = "mixed"
+	ResetHard  ResetMode = "hard"
+	ResetKeep  ResetMode = "keep"
+)
+
+// RestoreOptions defines the options supported by Restore.
+type RestoreOptions struct {
+	Paths []string `json:"paths"`
+
+	// Source is the revision to restore from. If empty, the working tree
+	// is restored from the index, and the index from HEAD.
+	Source string `json:"source,omitempty"`
+
+	// Staged restores the index.
+	Staged bool `json:"staged,omitempty"`
+
+	// Worktree restores the working tree. It is implied when Staged is false.
+	Worktree bool `json:"worktree,omitempty"`
+}
+
+// StashEntry defines a stash entry.
 type StashEntry struct {
-	Name        string `json:"name"`
-	Branch      string `json:"branch"`
+	// Index is the position of the entry in the stash, the most recent being 0.
+	Index int `json:"index"`
+
+	// Name is the entry's reference, e.g. `stash@{0}`.
+	Name string `json:"name"`
+
+	// Hash is the hash of the entry's commit.
+	Hash string `json:"hash"`
+
+	// Branch is the branch the entry was created on.
+	Branch string `json:"branch"`
+
+	// Description is the entry's message.
 	Description string `json:"description"`
+
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// StashOptions defines the options supported by StashWithOptions.
+type StashOptions struct {
+	Message string `json:"message"`
+
+	// Untracked includes untracked files.
+	Untracked bool `json:"untracked,omitempty"`
+
+	// KeepIndex leaves changes already in staging intact.
+	KeepIndex bool `json:"keepIndex,omitempty"`
+
+	// Pathspecs, if given, restricts the stash to the matching paths.
+	Pathspecs []string `json:"pathspecs,omitempty"`
 }
 
+// ErrNothingToStash is returned when there are no local changes to stash.
+var ErrNothingToStash = errors.New("no local changes to stash")
+
 // HasGit checks if the git command exists in PATH.
 func HasGit() bool {
 	s, err := exec.LookPath("git")
@@ -2,11 +2,14 @@ package git
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -15,15 +18,21 @@ import (
 )
 
 // NewHandler retusn a new git interface for the given directory.
+// If the git command is not available, the read-only handler returned
+// by NewNativeHandler is used instead.
 func NewHandler(dir string) (Handler, error) {
 	if !HasGit() {
-		return nil, fmt.Errorf("Unable to find the git command")
+		g, err := NewNativeHandler(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to find the git command: %w", err)
+		}
+		return g, nil
 	}
 
 	rootDir, err := getRootDir(dir)
 	if err != nil {
-		// NOTE: not a git directory (yet)
-		err = nil
+		// NOTE: not a git directory (yet), or not even created
+		rootDir = dir
 	}
 
 	return &handlerImpl{root: rootDir, log: slog.Default().WithGroup("git")}, nil
@@ -31,8 +40,11 @@ func NewHandler(dir string) (Handler, error) {
 
 // handlerImp implements the Handler interface.
 type handlerImpl struct {
-	root string
-	log  *slog.Logger
+	root    string
+	log     *slog.Logger
+	env     []string
+	envOpts envState
+	trace   commandTrace
 }
 
 func (h *handlerImpl) AddToStaging(files []string) (err error) {
@@ -50,6 +62,47 @@ func (h *handlerImpl) AddToStaging(files []string) (err error) {
 	return
 }
 
+func (h *handlerImpl) AheadBehind(rev, upstream string) (ahead, behind int, err error) {
+	h.log.With(
+		"rev", rev,
+		"upstream", upstream,
+	).Info("Counting commits ahead and behind")
+
+	out, err := h.execute("rev-list", "--count", "--left-right", rev+"..."+upstream, "--")
+	if err != nil {
+		return
+	}
+
+	left, right, found := strings.Cut(strings.TrimSpace(string(out)), "\t")
+	if !found {
+		err = fmt.Errorf("unexpected rev-list output: %q", out)
+		return
+	}
+
+	if ahead, err = strconv.Atoi(left); err != nil {
+		return
+	}
+
+	behind, err = strconv.Atoi(right)
+	return
+}
+
+func (h *handlerImpl) ApplyStash(index int, restoreIndex ...bool) error {
+	h.log.With(
+		"index", index,
+		"restore-index", restoreIndex,
+	).Info("Applying stash entry")
+
+	args := []string{"stash", "apply"}
+	if len(restoreIndex) > 0 && restoreIndex[0] {
+		args = append(args, "--index")
+	}
+
+	args = append(args, stashRef(index))
+
+	return h.executeNO(args...)
+}
+
 func (h *handlerImpl) Branch() (name string, err error) {
 	h.log.Info("Returning active branch")
 
@@ -107,6 +160,93 @@ func (h *handlerImpl) CheckoutNewBranch(name string) error {
 	return nil
 }
 
+func (h *handlerImpl) Clean(opts CleanOptions) (paths []string, err error) {
+	h.log.With(
+		"dry-run", opts.DryRun,
+		"directories", opts.Directories,
+		"ignored", opts.Ignored,
+		"only-ignored", opts.OnlyIgnored,
+		"pathspecs", opts.Pathspecs,
+	).Info("Cleaning working tree")
+
+	args := []string{"-c", "core.quotePath=false", "clean"}
+	if opts.DryRun {
+		args = append(args, "--dry-run")
+	} else {
+		args = append(args, "--force")
+	}
+	if opts.Directories {
+		args = append(args, "-d")
+	}
+	if opts.OnlyIgnored {
+		args = append(args, "-X")
+	} else if opts.Ignored {
+		args = append(args, "-x")
+	}
+	if len(opts.Pathspecs) > 0 {
+		args = append(args, "--")
+		args = append(args, opts.Pathspecs...)
+	}
+
+	out, err := h.execute(args...)
+	if err != nil {
+		return
+	}
+
+	for _, l := range splitLines(out) {
+		if p, ok := strings.CutPrefix(l, "Would remove "); ok {
+			paths = append(paths, p)
+		} else if p, ok := strings.CutPrefix(l, "Removing "); ok {
+			paths = append(paths, p)
+		}
+	}
+
+	return
+}
+
+func (h *handlerImpl) Clone(url string, opts CloneOptions) error {
+	h.log.With(
+		"url", url,
+		"branch", opts.Branch,
+		"origin", opts.Origin,
+		"filter", opts.Filter,
+		"sparse", opts.Sparse,
+		"no-checkout", opts.NoCheckout,
+		"depth", opts.Depth,
+	).Info("Cloning repository")
+
+	if err := os.MkdirAll(h.root, 0755); err != nil {
+		return err
+	}
+
+	args := []string{"clone", "--quiet"}
+	if opts.Branch != "" {
+		args = append(args, "--branch", opts.Branch)
+	}
+	if opts.Origin != "" {
+		args = append(args, "--origin", opts.Origin)
+	}
+	if opts.Filter != "" {
+		args = append(args, "--filter", opts.Filter)
+	}
+	if opts.Sparse {
+		args = append(args, "--sparse")
+	}
+	if opts.NoCheckout {
+		args = append(args, "--no-checkout")
+	}
+	if opts.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(opts.Depth))
+	}
+	args = append(args, "--", url, ".")
+
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) Close() error {
+	return nil
+}
+
 func (h *handlerImpl) Commit(msg string) (err error) {
 	h.log.Info("Committing", "msg", msg)
 
@@ -134,6 +274,85 @@ func (h *handlerImpl) CommitFiles(files []string, msg string) (err error) {
 	return
 }
 
+func (h *handlerImpl) CommitWithOptions(opts CommitOptions) (hash string, err error) {
+	h.log.With(
+		"msg", opts.Message,
+		"files", opts.Files,
+		"author", opts.Author,
+		"amend", opts.Amend,
+		"allow-empty", opts.AllowEmpty,
+	).Info("Committing with options")
+
+	if opts.Message == "" && !opts.Amend {
+		err = fmt.Errorf("a commit message is required")
+		return
+	}
+
+	if len(opts.Files) > 0 {
+		opts.Files = h.makeAbsPath(opts.Files)
+		if err = h.AddToStaging(opts.Files); err != nil {
+			return
+		}
+	}
+
+	if opts.Policy != nil {
+		var report PolicyReport
+		if report, err = h.CheckPolicy(opts.Policy); err == nil {
+			err = report.Err()
+		}
+		if err != nil {
+			if len(opts.Files) > 0 {
+				_ = h.RemoveFromStaging(opts.Files, true)
+			}
+			return
+		}
+	}
+
+	args := []string{"commit"}
+	if opts.Message != "" {
+		args = append(args, "--message", opts.Message)
+	} else {
+		args = append(args, "--no-edit")
+	}
+	if opts.Author != "" {
+		args = append(args, "--author", opts.Author)
+	}
+	if opts.Amend {
+		args = append(args, "--amend")
+	}
+	if opts.AllowEmpty {
+		args = append(args, "--allow-empty")
+	}
+	if opts.SignOff {
+		args = append(args, "--signoff")
+	}
+	for _, t := range opts.Trailers {
+		args = append(args, "--trailer", t.String())
+	}
+
+	var env []string
+	if !opts.Date.IsZero() {
+		date := opts.Date.Format(time.RFC3339)
+		args = append(args, "--date", date)
+		env = append(env, "GIT_COMMITTER_DATE="+date)
+	}
+
+	if _, err = h.executeEnv(env, args...); err != nil {
+		if len(opts.Files) > 0 {
+			_ = h.RemoveFromStaging(opts.Files, true)
+		}
+		return
+	}
+
+	out, err := h.execute("rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return
+	}
+
+	hash = strings.TrimSuffix(string(out), "\n")
+	return
+}
+
 func (h *handlerImpl) Config(key string) (value string, err error) {
 	h.log.Info("Getting config value", "key", key)
 
@@ -193,6 +412,12 @@ func (h *handlerImpl) DropStash(clear ...bool) error {
 	return h.executeNO(args...)
 }
 
+func (h *handlerImpl) DropStashAt(index int) error {
+	h.log.Info("Dropping stash entry", "index", index)
+
+	return h.executeNO("stash", "drop", stashRef(index))
+}
+
 func (h *handlerImpl) Describe(hash string, exact ...bool) (string, error) {
 	h.log.With(
 		"hash", hash,
@@ -240,6 +465,24 @@ func (h *handlerImpl) FileChanged(file string) bool {
 	return len(diff) > 0
 }
 
+func (h *handlerImpl) IsAncestor(ancestor, rev string) (bool, error) {
+	h.log.With(
+		"ancestor", ancestor,
+		"rev", rev,
+	).Info("Checking ancestry")
+
+	err := h.executeNO("merge-base", "--is-ancestor", ancestor, rev)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
 func (h *handlerImpl) Init(initialBranch string) error {
 	h.log.Info("Initializing git repository", "initial-branch", initialBranch)
 
@@ -250,19 +493,60 @@ func (h *handlerImpl) Init(initialBranch string) error {
 	return h.executeNO("init")
 }
 
-func (h *handlerImpl) LatestHash(noFetch ...bool) (hash string, err error) {
-	h.log.Info("Getting latest hash", "no-fetch", noFetch)
+func (h *handlerImpl) InitWithOptions(opts InitOptions) error {
+	h.log.With(
+		"initial-branch", opts.InitialBranch,
+		"bare", opts.Bare,
+		"separate-git-dir", opts.SeparateGitDir,
+		"template", opts.Template,
+		"shared", opts.Shared,
+		"object-format", opts.ObjectFormat,
+		"scaffold", opts.Scaffold != nil,
+	).Info("Initializing git repository with options")
+
+	switch opts.ObjectFormat {
+	case "", ObjectFormatSHA1, ObjectFormatSHA256:
+	default:
+		return fmt.Errorf("unsupported object format: %q", opts.ObjectFormat)
+	}
 
-	doFetch := true
-	if len(noFetch) > 0 && noFetch[0] {
-		doFetch = false
+	if err := os.MkdirAll(h.root, 0755); err != nil {
+		return err
 	}
 
-	if doFetch {
-		if err = h.Fetch(""); err != nil {
-			return
-		}
+	args := []string{"init", "--quiet"}
+	if opts.InitialBranch != "" {
+		args = append(args, "--initial-branch", opts.InitialBranch)
+	}
+	if opts.Bare {
+		args = append(args, "--bare")
+	}
+	if opts.SeparateGitDir != "" {
+		args = append(args, "--separate-git-dir", opts.SeparateGitDir)
 	}
+	if opts.Template != "" {
+		args = append(args, "--template", opts.Template)
+	}
+	if opts.Shared != "" {
+		args = append(args, "--shared="+opts.Shared)
+	}
+	if opts.ObjectFormat != "" {
+		args = append(args, "--object-format", string(opts.ObjectFormat))
+	}
+
+	if err := h.executeNO(args...); err != nil {
+		return err
+	}
+
+	if opts.Scaffold == nil {
+		return nil
+	}
+
+	return h.Scaffold(*opts.Scaffold)
+}
+
+func (h *handlerImpl) LatestHash(noFetch ...bool) (hash string, err error) {
+	h.log.Info("Getting latest hash")
 
 	out, err := h.execute("rev-parse", "--verify", "HEAD")
 	if err != nil {
@@ -347,6 +631,26 @@ func (h *handlerImpl) Log(maxCount int) ([]LogEntry, error) {
 	return list, nil
 }
 
+func (h *handlerImpl) MergeBase(revs ...string) (hash string, err error) {
+	h.log.Info("Getting merge base", "revs", revs)
+
+	if len(revs) < 2 {
+		err = fmt.Errorf("at least two revisions are required")
+		return
+	}
+
+	args := []string{"merge-base"}
+	args = append(args, revs...)
+
+	out, err := h.execute(args...)
+	if err != nil {
+		return
+	}
+
+	hash = strings.TrimSuffix(string(out), "\n")
+	return
+}
+
 func (h *handlerImpl) MergeStash(remote, branch, commitMsg string) error {
 	h.log.With(
 		"remote", remote,
@@ -355,6 +659,9 @@ func (h *handlerImpl) MergeStash(remote, branch, commitMsg string) error {
 	).Info("Performing Stash + Pull + Merge Stash")
 
 	_, err := h.Stash("", true)
+	if errors.Is(err, ErrNothingToStash) {
+		return h.Pull(remote, branch)
+	}
 	if err != nil {
 		onerror.Log(h.PopStash(""))
 		return err
@@ -428,6 +735,12 @@ func (h *handlerImpl) PopStash(msg string) error {
 	return h.executeNO(args...)
 }
 
+func (h *handlerImpl) PopStashAt(index int) error {
+	h.log.Info("Popping stash entry", "index", index)
+
+	return h.executeNO("stash", "pop", stashRef(index))
+}
+
 func (h *handlerImpl) Pull(remote, branch string, noCommit ...bool) error {
 	h.log.With(
 		"remote", remote,
@@ -508,6 +821,172 @@ func (h *handlerImpl) RemoveFromStaging(files []string, ignoreErrors ...bool) (e
 	return
 }
 
+func (h *handlerImpl) Reset(mode ResetMode, rev string) (paths []string, err error) {
+	h.log.With(
+		"mode", mode,
+		"rev", rev,
+	).Info("Resetting current branch")
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	var diffArgs []string
+	switch mode {
+	case ResetSoft:
+		// NOTE: a soft reset keeps the index and working tree, so nothing is discarded
+		err = h.executeNO("reset", "--soft", rev)
+		return
+	case ResetMixed:
+		diffArgs = []string{"diff", "--name-only", "-z", "--cached", rev}
+	case ResetHard:
+		diffArgs = []string{"diff", "--name-only", "-z", rev}
+	case ResetKeep:
+		diffArgs = []string{"diff", "--name-only", "-z", "HEAD", rev}
+	default:
+		err = fmt.Errorf("unsupported reset mode: %q", mode)
+		return
+	}
+
+	out, err := h.execute(diffArgs...)
+	if err != nil {
+		return
+	}
+
+	if err = h.executeNO("reset", "--"+string(mode), rev); err != nil {
+		return
+	}
+
+	paths = splitNUL(out)
+	return
+}
+
+func (h *handlerImpl) ResolveRev(rev string) (hash string, err error) {
+	h.log.Info("Resolving revision", "rev", rev)
+
+	out, err := h.execute("rev-parse", "--verify", "--quiet", "--end-of-options", rev+"^{commit}")
+	if err != nil {
+		err = fmt.Errorf("unable to resolve revision %q: %w", rev, err)
+		return
+	}
+
+	hash = strings.TrimSuffix(string(out), "\n")
+	return
+}
+
+func (h *handlerImpl) Restore(opts RestoreOptions) (paths []string, err error) {
+	h.log.With(
+		"paths", opts.Paths,
+		"source", opts.Source,
+		"staged", opts.Staged,
+		"worktree", opts.Worktree,
+	).Info("Restoring paths")
+
+	if len(opts.Paths) == 0 {
+		err = fmt.Errorf("no paths to restore were given")
+		return
+	}
+
+	worktree := opts.Worktree || !opts.Staged
+
+	var affected []string
+	if worktree {
+		args := []string{"diff", "--name-only", "-z"}
+		if opts.Source != "" {
+			args = append(args, opts.Source)
+		}
+		args = append(args, "--")
+		args = append(args, opts.Paths...)
+
+		out, err := h.execute(args...)
+		if err != nil {
+			return nil, err
+		}
+		affected = append(affected, splitNUL(out)...)
+	}
+	if opts.Staged {
+		source := opts.Source
+		if source == "" {
+			source = "HEAD"
+		}
+
+		args := []string{"diff", "--name-only", "-z", "--cached", source, "--"}
+		args = append(args, opts.Paths...)
+
+		out, err := h.execute(args...)
+		if err != nil {
+			return nil, err
+		}
+		affected = append(affected, splitNUL(out)...)
+	}
+
+	args := []string{"restore"}
+	if opts.Source != "" {
+		args = append(args, "--source", opts.Source)
+	}
+	if opts.Staged {
+		args = append(args, "--staged")
+	}
+	if worktree {
+		args = append(args, "--worktree")
+	}
+	args = append(args, "--")
+	args = append(args, opts.Paths...)
+
+	if err = h.executeNO(args...); err != nil {
+		return
+	}
+
+	slices.Sort(affected)
+	paths = slices.Compact(affected)
+	return
+}
+
+func (h *handlerImpl) RevListCount(from, to string) (int, error) {
+	h.log.With(
+		"from", from,
+		"to", to,
+	).Info("Counting commits")
+
+	rng := to
+	if from != "" {
+		rng = from + ".." + to
+	}
+
+	out, err := h.execute("rev-list", "--count", rng, "--")
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.Atoi(strings.TrimSpace(string(out)))
+}
+
+func (h *handlerImpl) Revert(commits []string, noCommit ...bool) error {
+	h.log.With(
+		"commits", commits,
+		"no-commit", noCommit,
+	).Info("Reverting commits")
+
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits to revert were given")
+	}
+
+	args := []string{"revert"}
+	if len(noCommit) > 0 && noCommit[0] {
+		args = append(args, "--no-commit")
+	} else {
+		args = append(args, "--no-edit")
+	}
+	args = append(args, commits...)
+
+	if err := h.executeNO(args...); err != nil {
+		onerror.Log(h.executeNO("revert", "--abort"))
+		return err
+	}
+
+	return nil
+}
+
 func (h *handlerImpl) SetConfig(key string, value string) error {
 	h.log.With(
 		"key", key,
@@ -546,69 +1025,145 @@ func (h *handlerImpl) SetUpstreamBranchTo(remote, branch string) error {
 	return h.executeNO("branch", "--set-upstream-to", remote+"/"+branch)
 }
 
-func (h *handlerImpl) Stash(msg string, untracked ...bool) (entry StashEntry, err error) {
+func (h *handlerImpl) ShortHash(rev string, length ...int) (hash string, err error) {
 	h.log.With(
-		"msg", msg,
-		"untracked", untracked,
-	).Info("Stashing changes")
+		"rev", rev,
+		"length", length,
+	).Info("Abbreviating hash")
 
-	args := []string{"stash"}
-
-	if msg != "" {
-		args = append(args, "--message", msg)
+	short := "--short"
+	if len(length) > 0 && length[0] > 0 {
+		short += "=" + strconv.Itoa(length[0])
 	}
 
-	if len(untracked) > 0 && untracked[0] {
-		args = append(args, "--include-untracked")
+	out, err := h.execute("rev-parse", "--verify", short, "--end-of-options", rev)
+	if err != nil {
+		return
 	}
 
-	err = h.executeNO(args...)
+	hash = strings.TrimSuffix(string(out), "\n")
+	return
+}
+
+func (h *handlerImpl) ShowStash(index int) (files []string, diff string, err error) {
+	h.log.Info("Showing stash entry", "index", index)
+
+	ref := stashRef(index)
+
+	out, err := h.execute("stash", "show", "--name-only", "--include-untracked", ref)
 	if err != nil {
 		return
 	}
 
-	entries, err := h.StashList()
+	files = splitLines(out)
+
+	out, err = h.execute("stash", "show", "--patch", "--include-untracked", ref)
 	if err != nil {
 		return
 	}
 
-	entry = entries[0]
-
+	diff = strings.TrimSuffix(string(out), "\n")
 	return
 }
 
+func (h *handlerImpl) Stash(msg string, untracked ...bool) (entry StashEntry, err error) {
+	return h.StashWithOptions(StashOptions{
+		Message:   msg,
+		Untracked: len(untracked) > 0 && untracked[0],
+	})
+}
+
+func (h *handlerImpl) StashBranch(name string, index int) error {
+	h.log.With(
+		"name", name,
+		"index", index,
+	).Info("Creating branch from stash entry")
+
+	return h.executeNO("stash", "branch", name, stashRef(index))
+}
+
 func (h *handlerImpl) StashList() ([]StashEntry, error) {
-	out, err := h.execute("stash", "list")
+	out, err := h.execute("stash", "list", "--format=%gd%x1f%H%x1f%ct%x1f%gs")
 	if err != nil {
 		return nil, err
 	}
 
-	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
-	if len(lines) == 0 {
-		return nil, err
-	}
-
 	list := []StashEntry{}
-	for _, l := range lines {
-		parts := strings.Split(l, ":")
-		if len(parts) == 0 {
+	for i, l := range strings.Split(string(out), "\n") {
+		if l == "" {
 			continue
 		}
 
-		for i := range parts {
-			parts[i] = strings.TrimSpace(parts[i])
+		entry, err := parseStashEntry(l)
+		if err != nil {
+			h.log.With(
+				"line", i+1,
+				"error", err,
+			).Error("Failed to parse stash entry")
+			return nil, err
 		}
 
-		entry := StashEntry{Name: parts[0], Branch: parts[1]}
-		if len(parts) > 2 {
-			entry.Description = parts[2]
-		}
 		list = append(list, entry)
 	}
 
 	return list, nil
 }
 
+func (h *handlerImpl) StashWithOptions(opts StashOptions) (entry StashEntry, err error) {
+	h.log.With(
+		"msg", opts.Message,
+		"untracked", opts.Untracked,
+		"keep-index", opts.KeepIndex,
+		"pathspecs", opts.Pathspecs,
+	).Info("Stashing changes")
+
+	before, _ := h.execute("rev-parse", "--quiet", "--verify", "refs/stash")
+
+	args := []string{"stash", "push"}
+
+	if opts.Message != "" {
+		args = append(args, "--message", opts.Message)
+	}
+
+	if opts.Untracked {
+		args = append(args, "--include-untracked")
+	}
+
+	if opts.KeepIndex {
+		args = append(args, "--keep-index")
+	}
+
+	if len(opts.Pathspecs) > 0 {
+		args = append(args, "--")
+		args = append(args, opts.Pathspecs...)
+	}
+
+	err = h.executeNO(args...)
+	if err != nil {
+		return
+	}
+
+	after, _ := h.execute("rev-parse", "--quiet", "--verify", "refs/stash")
+	if len(after) == 0 || bytes.Equal(before, after) {
+		err = ErrNothingToStash
+		return
+	}
+
+	entries, err := h.StashList()
+	if err != nil {
+		return
+	}
+
+	if len(entries) == 0 {
+		err = ErrNothingToStash
+		return
+	}
+
+	entry = entries[0]
+
+	return
+}
+
 func (h *handlerImpl) Status() (staged, unstaged, untracked []string, err error) {
 	h.log.Info("Getting status")
 
@@ -648,6 +1203,18 @@ func (h *handlerImpl) Status() (staged, unstaged, untracked []string, err error)
 	return
 }
 
+func (h *handlerImpl) SymbolicRef(name string) (ref string, err error) {
+	h.log.Info("Reading symbolic reference", "name", name)
+
+	out, err := h.execute("symbolic-ref", "--quiet", name)
+	if err != nil {
+		return
+	}
+
+	ref = strings.TrimSuffix(string(out), "\n")
+	return
+}
+
 func (h *handlerImpl) TopLevel() string {
 	h.log.Info("Returning top level")
 
@@ -675,23 +1242,58 @@ func (h *handlerImpl) executeNO(in ...string) error {
 }
 
 func (h *handlerImpl) execute(in ...string) ([]byte, error) {
-	args := []string{"-C", h.root}
-	args = append(args, in...)
+	return h.executeEnv(nil, in...)
+}
 
-	cmd := exec.Command("git", args...)
-	outb := &bytes.Buffer{}
-	errb := &bytes.Buffer{}
-	cmd.Stdout = outb
-	cmd.Stderr = errb
+// executeEnv runs the git command with the given environment
+// variables added to the current process' and handler's ones.
+func (h *handlerImpl) executeEnv(env []string, in ...string) ([]byte, error) {
+	return h.run(runOptions{env: env}, in...)
+}
 
-	slog.Debug("Running git command", "cmd", cmd)
+// executeUntraced runs the git command without reporting it to
+// observers or the audit file.
+func (h *handlerImpl) executeUntraced(in ...string) ([]byte, error) {
+	return h.run(runOptions{untraced: true}, in...)
+}
+
+// executeStdin runs the git command with the given standard input.
+func (h *handlerImpl) executeStdin(stdin io.Reader, in ...string) ([]byte, error) {
+	return h.run(runOptions{stdin: stdin}, in...)
+}
+
+// executeTo runs the git command, streaming its standard output to w.
+func (h *handlerImpl) executeTo(w io.Writer, in ...string) error {
+	_, err := h.run(runOptions{stdout: w}, in...)
+	return err
+}
 
-	err := cmd.Run()
+// gitDir returns the absolute path to the repository's git directory.
+func (h *handlerImpl) gitDir() (string, error) {
+	out, err := h.execute("rev-parse", "--absolute-git-dir")
 	if err != nil {
-		err = fmt.Errorf("%s: %w", errb.String(), err)
+		return "", err
 	}
 
-	return outb.Bytes(), err
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// logEntries runs the given `git log`-like command and parses its output.
+func (h *handlerImpl) logEntries(in ...string) ([]LogEntry, error) {
+	return h.logEntriesWithFormat(logEntryFormat, in...)
+}
+
+// logEntriesWithFormat is like logEntries, for any of the formats
+// understood by parseLogEntries.
+func (h *handlerImpl) logEntriesWithFormat(format string, in ...string) ([]LogEntry, error) {
+	args := append(slices.Clone(in), "--pretty="+format)
+
+	out, err := h.execute(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseLogEntries(out)
 }
 
 func (h *handlerImpl) makeAbsPath(files []string) []string {
@@ -717,27 +1319,210 @@ func (h *handlerImpl) makeAbsPath(files []string) []string {
 	return newFiles
 }
 
+// runOptions defines the options for running a git command.
+type runOptions struct {
+	// env is added to the current process' and handler's environment.
+	env []string
+
+	stdin io.Reader
+
+	// stdout, if set, receives the command's output instead of it being returned.
+	stdout io.Writer
+
+	// stderr, if set, also receives the command's error output.
+	stderr io.Writer
+
+	// untraced skips observers and the audit file, e.g. for polling.
+	untraced bool
+}
+
+// run runs the git command in the root directory.
+func (h *handlerImpl) run(opts runOptions, in ...string) ([]byte, error) {
+	if h.isDryRun(in) {
+		slog.Debug("Skipping git command in dry-run mode", "args", in)
+		h.report(h.dryRunEvent(in))
+		return nil, ErrDryRun
+	}
+
+	credArgs, stopCredentials, err := h.credentialArgs(in)
+	if err != nil {
+		return nil, err
+	}
+	defer stopCredentials()
+
+	args := []string{"-C", h.root}
+	args = append(args, credArgs...)
+	args = append(args, in...)
+
+	cmd := exec.Command("git", args...)
+	cmd.Env = h.environ(opts.env)
+	cmd.Stdin = opts.stdin
+
+	outb := &bytes.Buffer{}
+	errb := &bytes.Buffer{}
+	cmd.Stdout = outb
+	cmd.Stderr = errb
+	if opts.stdout != nil {
+		cmd.Stdout = opts.stdout
+	}
+	if opts.stderr != nil {
+		cmd.Stderr = io.MultiWriter(errb, opts.stderr)
+	}
+
+	traced := h.traced() && !opts.untraced
+	traceOut := &limitedBuffer{}
+	traceErr := &limitedBuffer{}
+	if traced {
+		cmd.Stdout = io.MultiWriter(cmd.Stdout, traceOut)
+		cmd.Stderr = io.MultiWriter(cmd.Stderr, traceErr)
+	}
+
+	slog.Debug("Running git command", "cmd", cmd)
+
+	start := time.Now()
+	err = cmd.Run()
+
+	if traced {
+		h.report(h.newCommandEvent(in, start, traceOut, traceErr, err))
+	}
+
+	if err != nil {
+		err = fmt.Errorf("%s: %w", errb.String(), err)
+	}
+
+	return outb.Bytes(), err
+}
+
+// setEnv sets an environment variable for every command run by the handler.
+func (h *handlerImpl) setEnv(name, value string) {
+	prefix := name + "="
+	h.env = slices.DeleteFunc(h.env, func(e string) bool {
+		return strings.HasPrefix(e, prefix)
+	})
+	h.env = append(h.env, prefix+value)
+}
+
 func getRootDir(dir string) (rootDir string, err error) {
-	pwd, err := os.Getwd()
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "--show-toplevel").Output()
 	if err != nil {
+		rootDir = dir
 		return
 	}
 
-	if pwd != dir {
-		err = os.Chdir(dir)
+	rootDir = strings.TrimSuffix(string(out), "\n")
+	return
+}
+
+// logEntryFormat is the --pretty format parsed by parseLogEntries.
+// Records are terminated by RS and fields separated by US, so that
+// bodies can span multiple lines.
+const logEntryFormat = "%H%x1f%at%x1f%an%x1f%ae%x1f%s%x1f%b%x1e"
+
+// logEntryNotesFormat is like logEntryFormat, including notes.
+const logEntryNotesFormat = "%H%x1f%at%x1f%an%x1f%ae%x1f%s%x1f%b%x1f%N%x1e"
+
+// parseLogEntries parses output formatted with logEntryFormat or logEntryNotesFormat.
+func parseLogEntries(out []byte) ([]LogEntry, error) {
+	list := []LogEntry{}
+	for i, rec := range strings.Split(string(out), "\x1e") {
+		rec = strings.TrimLeft(rec, "\n")
+		if rec == "" {
+			continue
+		}
+
+		s := strings.SplitN(rec, "\x1f", 7)
+		if len(s) < 6 {
+			slog.Error("Log record contains less elements than expected", "record", i+1)
+			continue
+		}
+
+		ts, err := strconv.ParseInt(s[1], 10, 64)
 		if err != nil {
-			return
+			slog.With(
+				"log-record", i+1,
+				"timestamp", s[1],
+				"error", err,
+			).Error("Failed to parse timestamp")
+			return nil, err
 		}
 
-		defer func() { os.Chdir(pwd) }()
+		entry := LogEntry{
+			Hash:      s[0],
+			Timestamp: time.Unix(ts, 0),
+			Author:    s[2],
+			Email:     s[3],
+			Subject:   s[4],
+			Body:      strings.TrimSuffix(s[5], "\n"),
+		}
+		if len(s) > 6 {
+			entry.Notes = strings.TrimSuffix(s[6], "\n")
+		}
+
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+// parseStashEntry parses a line of `git stash list`, as formatted by StashList.
+func parseStashEntry(line string) (entry StashEntry, err error) {
+	parts := strings.SplitN(line, "\x1f", 4)
+	if len(parts) < 4 {
+		err = fmt.Errorf("stash entry has less elements than expected: %q", line)
+		return
 	}
 
-	out, err := exec.Command("git", "rev-parse", "--show-toplevel").CombinedOutput()
+	entry.Name = parts[0]
+	entry.Hash = parts[1]
+
+	idx := strings.TrimSuffix(strings.TrimPrefix(parts[0], "stash@{"), "}")
+	if entry.Index, err = strconv.Atoi(idx); err != nil {
+		err = fmt.Errorf("invalid stash reference %q: %w", parts[0], err)
+		return
+	}
+
+	ts, err := strconv.ParseInt(parts[2], 10, 64)
 	if err != nil {
-		rootDir = dir
+		err = fmt.Errorf("invalid stash timestamp %q: %w", parts[2], err)
+		return
+	}
+	entry.Timestamp = time.Unix(ts, 0)
+
+	// The reflog subject is either `WIP on <branch>: <hash> <subject>`
+	// or `On <branch>: <message>`; branch names cannot contain colons.
+	subject := parts[3]
+	subject = strings.TrimPrefix(subject, "WIP on ")
+	subject = strings.TrimPrefix(subject, "On ")
+	branch, msg, found := strings.Cut(subject, ": ")
+	if !found {
+		entry.Description = parts[3]
 		return
 	}
 
-	rootDir = strings.TrimSuffix(string(out), "\n")
+	entry.Branch = branch
+	entry.Description = msg
 	return
 }
+
+// splitLines returns the non-empty lines in out.
+func splitLines(out []byte) (lines []string) {
+	for _, l := range strings.Split(string(out), "\n") {
+		if l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return
+}
+
+// splitNUL returns the non-empty elements in the NUL-separated out.
+func splitNUL(out []byte) (list []string) {
+	for _, l := range strings.Split(string(out), "\x00") {
+		if l != "" {
+			list = append(list, l)
+		}
+	}
+	return
+}
+
+func stashRef(index int) string {
+	return fmt.Sprintf("stash@{%d}", index)
+}
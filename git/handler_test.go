@@ -4,7 +4,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jwmwalrus/bnp/tests"
 	"github.com/jwmwalrus/bnp/tests/assert"
@@ -109,6 +111,90 @@ func TestCheckoutNewBranch(t *testing.T) {
 	}
 }
 
+func TestClean(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{".gitignore"}, "Initial commit")
+	assert.NoError(t, err)
+
+	err = os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	assert.NoError(t, err)
+	for _, f := range []string{"a.txt", "b.log", "sub/c.txt"} {
+		err = os.WriteFile(filepath.Join(dir, f), []byte{}, 0644)
+		assert.NoError(t, err)
+	}
+
+	paths, err := g.Clean(CleanOptions{DryRun: true, Directories: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "sub/"}, paths)
+
+	paths, err = g.Clean(CleanOptions{OnlyIgnored: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b.log"}, paths)
+
+	_, err = os.Stat(filepath.Join(dir, "b.log"))
+	assert.Equal(t, true, os.IsNotExist(err))
+
+	paths, err = g.Clean(CleanOptions{Directories: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a.txt", "sub/"}, paths)
+
+	_, _, untracked, err := g.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(untracked))
+}
+
+func TestCommitWithOptions(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := "file.txt"
+	err := os.WriteFile(filepath.Join(dir, file), []byte{}, 0644)
+	assert.NoError(t, err)
+
+	date := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	hash, err := g.CommitWithOptions(CommitOptions{
+		Message: "Initial commit",
+		Files:   []string{file},
+		Author:  "Bot <bot@example.com>",
+		Date:    date,
+		Trailers: []Trailer{
+			{Key: "Co-authored-by", Value: "Someone <someone@example.com>"},
+		},
+	})
+	assert.NoError(t, err)
+
+	latest, err := g.LatestHash(true)
+	assert.NoError(t, err)
+	assert.Equal(t, latest, hash)
+
+	out, err := exec.Command("git", "log", "-1", "--format=%an;%ae;%at;%ct;%(trailers:only,unfold)").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "Bot;bot@example.com;1577934245;1577934245;Co-authored-by: Someone <someone@example.com>\n\n", string(out))
+
+	empty, err := g.CommitWithOptions(CommitOptions{Message: "Marker", AllowEmpty: true})
+	assert.NoError(t, err)
+
+	amended, err := g.CommitWithOptions(CommitOptions{Amend: true, AllowEmpty: true, SignOff: true})
+	assert.NoError(t, err)
+
+	if amended == empty {
+		t.Fatalf("expected amended commit to differ from `%s`", empty)
+	}
+
+	entries, err := g.Log(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "Marker", entries[0].Subject)
+
+	_, err = g.CommitWithOptions(CommitOptions{})
+	assert.Error(t, err)
+}
+
 func TestDeleteBranch(t *testing.T) {
 	g, dir := newTestRepo(t, "main")
 	defer os.RemoveAll(dir)
@@ -306,6 +392,31 @@ func TestMergeStash(t *testing.T) {
 	err = os.Chdir(dir1)
 	assert.NoError(t, err)
 
+	other := "other.txt"
+	err = os.WriteFile(filepath.Join(dir1, other), []byte("other"), 0644)
+	assert.NoError(t, err)
+
+	err = g1.CommitFiles([]string{other}, "Other change")
+	assert.NoError(t, err)
+
+	err = g1.Push("origin", "main")
+	assert.NoError(t, err)
+
+	// repo2, nothing to stash:
+	err = os.Chdir(dir2)
+	assert.NoError(t, err)
+
+	err = g2.MergeStash("origin", "main", "Merged changes")
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir2, other))
+	assert.NoError(t, err)
+	assert.Equal(t, "other", string(data))
+
+	// repo1:
+	err = os.Chdir(dir1)
+	assert.NoError(t, err)
+
 	err = os.WriteFile(filepath.Join(dir1, file), []byte("test\ntest\ntest"), 0644)
 	assert.NoError(t, err)
 
@@ -480,6 +591,221 @@ func TestRemoveFromStaging(t *testing.T) {
 	assert.Equal(t, 0, len(staged))
 }
 
+func TestReset(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mode     ResetMode
+		paths    []string
+		staged   []string
+		unstaged []string
+		content  string
+	}{
+		{
+			name:    "soft",
+			mode:    ResetSoft,
+			staged:  []string{"file1.txt", "file2.txt"},
+			content: "local",
+		},
+		{
+			name:     "mixed",
+			mode:     ResetMixed,
+			paths:    []string{"file1.txt", "file2.txt"},
+			unstaged: []string{"file1.txt"},
+			content:  "local",
+		},
+		{
+			name:    "hard",
+			mode:    ResetHard,
+			paths:   []string{"file1.txt", "file2.txt"},
+			content: "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			g, dir := newTestRepo(t, "main")
+			defer os.RemoveAll(dir)
+
+			file1, file2 := "file1.txt", "file2.txt"
+			err := os.WriteFile(filepath.Join(dir, file1), []byte{}, 0644)
+			assert.NoError(t, err)
+
+			err = g.CommitFiles([]string{file1}, "Initial commit")
+			assert.NoError(t, err)
+
+			base, err := g.LatestHash(true)
+			assert.NoError(t, err)
+
+			err = os.WriteFile(filepath.Join(dir, file1), []byte("committed"), 0644)
+			assert.NoError(t, err)
+			err = os.WriteFile(filepath.Join(dir, file2), []byte{}, 0644)
+			assert.NoError(t, err)
+
+			err = g.CommitFiles([]string{file1, file2}, "Second commit")
+			assert.NoError(t, err)
+
+			err = os.WriteFile(filepath.Join(dir, file1), []byte("local"), 0644)
+			assert.NoError(t, err)
+
+			paths, err := g.Reset(tc.mode, base)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.paths, paths)
+
+			staged, unstaged, _, err := g.Status()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.staged, staged)
+			assert.Equal(t, tc.unstaged, unstaged)
+
+			content, err := os.ReadFile(filepath.Join(dir, file1))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.content, string(content))
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file1, file2 := "file1.txt", "file2.txt"
+	for _, f := range []string{file1, file2} {
+		err := os.WriteFile(filepath.Join(dir, f), []byte{}, 0644)
+		assert.NoError(t, err)
+	}
+
+	err := g.CommitFiles([]string{file1, file2}, "Initial commit")
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, file1), []byte("test"), 0644)
+	assert.NoError(t, err)
+
+	paths, err := g.Restore(RestoreOptions{Paths: []string{file1, file2}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{file1}, paths)
+
+	err = os.WriteFile(filepath.Join(dir, file2), []byte("test"), 0644)
+	assert.NoError(t, err)
+
+	err = g.AddToStaging([]string{file2})
+	assert.NoError(t, err)
+
+	paths, err = g.Restore(RestoreOptions{Paths: []string{file2}, Staged: true})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{file2}, paths)
+
+	staged, unstaged, _, err := g.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(staged))
+	assert.Equal(t, []string{file2}, unstaged)
+}
+
+func TestRevisions(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := "file.txt"
+	err := os.WriteFile(filepath.Join(dir, file), []byte{}, 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	base, err := g.LatestHash(true)
+	assert.NoError(t, err)
+
+	err = g.NewTag("v0.1.0", "Initial release")
+	assert.NoError(t, err)
+
+	err = g.CheckoutNewBranch("feature")
+	assert.NoError(t, err)
+
+	for _, content := range []string{"a", "b"} {
+		err = os.WriteFile(filepath.Join(dir, file), []byte(content), 0644)
+		assert.NoError(t, err)
+
+		err = g.CommitFiles([]string{file}, "Change "+content)
+		assert.NoError(t, err)
+	}
+
+	head, err := g.LatestHash(true)
+	assert.NoError(t, err)
+
+	resolved, err := g.ResolveRev("v0.1.0")
+	assert.NoError(t, err)
+	assert.Equal(t, base, resolved)
+
+	resolved, err = g.ResolveRev("HEAD~2")
+	assert.NoError(t, err)
+	assert.Equal(t, base, resolved)
+
+	_, err = g.ResolveRev("does-not-exist")
+	assert.Error(t, err)
+
+	ok, err := g.IsAncestor("main", "feature")
+	assert.NoError(t, err)
+	assert.Equal(t, true, ok)
+
+	ok, err = g.IsAncestor("feature", "main")
+	assert.NoError(t, err)
+	assert.Equal(t, false, ok)
+
+	_, err = g.IsAncestor("does-not-exist", "main")
+	assert.Error(t, err)
+
+	mb, err := g.MergeBase("main", "feature")
+	assert.NoError(t, err)
+	assert.Equal(t, base, mb)
+
+	count, err := g.RevListCount("main", "feature")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	ahead, behind, err := g.AheadBehind("feature", "main")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, ahead)
+	assert.Equal(t, 0, behind)
+
+	ref, err := g.SymbolicRef("HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "refs/heads/feature", ref)
+
+	short, err := g.ShortHash("HEAD", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, head[:10], short)
+}
+
+func TestRevert(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := "file.txt"
+	err := os.WriteFile(filepath.Join(dir, file), []byte{}, 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, file), []byte("test"), 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file}, "Some change")
+	assert.NoError(t, err)
+
+	hash, err := g.LatestHash(true)
+	assert.NoError(t, err)
+
+	err = g.Revert([]string{hash})
+	assert.NoError(t, err)
+
+	content, err := os.ReadFile(filepath.Join(dir, file))
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(content))
+
+	entries, err := g.Log(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(entries))
+}
+
 func TestSetConfig(t *testing.T) {
 	g, dir := newTestRepo(t, "main")
 	defer os.RemoveAll(dir)
@@ -545,6 +871,134 @@ func TestStash(t *testing.T) {
 	assert.Equal(t, 0, len(untracked))
 }
 
+func TestStashEntries(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	list, err := g.StashList()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(list))
+
+	file1 := "file1.txt"
+	err = os.WriteFile(filepath.Join(dir, file1), []byte{}, 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file1}, "Initial commit")
+	assert.NoError(t, err)
+
+	_, err = g.Stash("nothing")
+	assert.Equal(t, ErrNothingToStash, err)
+
+	err = os.WriteFile(filepath.Join(dir, file1), []byte("test"), 0644)
+	assert.NoError(t, err)
+
+	first, err := g.Stash("fix: message with a colon")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, first.Index)
+	assert.Equal(t, "stash@{0}", first.Name)
+	assert.Equal(t, "main", first.Branch)
+	assert.Equal(t, "fix: message with a colon", first.Description)
+
+	file2 := "file2.txt"
+	err = os.WriteFile(filepath.Join(dir, file1), []byte("test2"), 0644)
+	assert.NoError(t, err)
+	err = os.WriteFile(filepath.Join(dir, file2), []byte("test"), 0644)
+	assert.NoError(t, err)
+
+	_, err = g.StashWithOptions(StashOptions{
+		Message:   "only file2",
+		Untracked: true,
+		Pathspecs: []string{file2},
+	})
+	assert.NoError(t, err)
+
+	_, unstaged, untracked, err := g.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{file1}, unstaged)
+	assert.Equal(t, 0, len(untracked))
+
+	list, err = g.StashList()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, first.Hash, list[1].Hash)
+
+	files, diff, err := g.ShowStash(1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{file1}, files)
+	assert.Equal(t, true, strings.Contains(diff, "+test"))
+
+	err = g.DropStashAt(1)
+	assert.NoError(t, err)
+
+	err = g.StashBranch("from-stash", 0)
+	assert.NoError(t, err)
+
+	current, err := g.Branch()
+	assert.NoError(t, err)
+	assert.Equal(t, "from-stash", current)
+
+	list, err = g.StashList()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(list))
+}
+
+func TestApplyStash(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file1 := "file1.txt"
+	file2 := "file2.txt"
+	for _, f := range []string{file1, file2} {
+		err := os.WriteFile(filepath.Join(dir, f), []byte{}, 0644)
+		assert.NoError(t, err)
+	}
+
+	err := g.CommitFiles([]string{file1, file2}, "Initial commit")
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, file1), []byte("one"), 0644)
+	assert.NoError(t, err)
+
+	_, err = g.Stash("one")
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, file2), []byte("two"), 0644)
+	assert.NoError(t, err)
+
+	err = g.AddToStaging([]string{file2})
+	assert.NoError(t, err)
+
+	_, err = g.Stash("two")
+	assert.NoError(t, err)
+
+	err = g.ApplyStash(0, true)
+	assert.NoError(t, err)
+
+	staged, unstaged, _, err := g.Status()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{file2}, staged)
+	assert.Equal(t, 0, len(unstaged))
+
+	list, err := g.StashList()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+
+	err = g.PopStashAt(1)
+	assert.NoError(t, err)
+
+	data, err := os.ReadFile(filepath.Join(dir, file1))
+	assert.NoError(t, err)
+	assert.Equal(t, "one", string(data))
+
+	list, err = g.StashList()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(list))
+	assert.Equal(t, "two", list[0].Description)
+
+	err = g.PopStashAt(1)
+	assert.Error(t, err)
+}
+
 func newTestRepo(t *testing.T, initialBranch string) (g Handler, dir string) {
 	dir = tests.NewTempDir(t)
 
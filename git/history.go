@@ -0,0 +1,193 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FileChange defines how a file was changed by a commit.
+type FileChange string
+
+// Supported file changes, as reported by `git log --name-status`.
+const (
+	FileAdded       FileChange = "added"
+	FileModified    FileChange = "modified"
+	FileDeleted     FileChange = "deleted"
+	FileRenamed     FileChange = "renamed"
+	FileCopied      FileChange = "copied"
+	FileTypeChanged FileChange = "typeChanged"
+)
+
+var fileChangeStatus = map[byte]FileChange{
+	'A': FileAdded,
+	'M': FileModified,
+	'D': FileDeleted,
+	'R': FileRenamed,
+	'C': FileCopied,
+	'T': FileTypeChanged,
+}
+
+// FileHistoryEntry defines a commit in the history of a file.
+type FileHistoryEntry struct {
+	LogEntry
+
+	// Path is the file's path, relative to the repository root, as of this commit.
+	Path string `json:"path"`
+
+	// OldPath is the file's previous path, for renames and copies.
+	OldPath string `json:"oldPath,omitempty"`
+
+	Change FileChange `json:"change"`
+
+	// Blob is the hash of the file's contents as of this commit,
+	// or empty if the file was deleted.
+	Blob string `json:"blob,omitempty"`
+
+	// Added and Deleted are the number of lines changed, unless Binary is set.
+	Added   int  `json:"added"`
+	Deleted int  `json:"deleted"`
+	Binary  bool `json:"binary,omitempty"`
+}
+
+func (h *handlerImpl) FileAt(rev, path string) ([]byte, error) {
+	h.log.With(
+		"rev", rev,
+		"path", path,
+	).Info("Getting file contents")
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	return h.execute("cat-file", "blob", rev+":"+path)
+}
+
+func (h *handlerImpl) FileHistory(path string, maxCount int) (list []FileHistoryEntry, err error) {
+	h.log.With(
+		"path", path,
+		"max-count", maxCount,
+	).Info("Getting file history")
+
+	args := []string{
+		"-c", "core.quotePath=false",
+		"log", "--follow", "--find-renames", "--no-abbrev",
+		"--format=%x1e%H", "--raw", "--numstat",
+	}
+	if maxCount > 0 {
+		args = append(args, "--max-count", strconv.Itoa(maxCount))
+	}
+	args = append(args, "--", path)
+
+	out, err := h.execute(args...)
+	if err != nil {
+		return
+	}
+
+	list = []FileHistoryEntry{}
+	var hashes []string
+	for _, rec := range strings.Split(string(out), "\x1e") {
+		lines := splitLines([]byte(rec))
+		if len(lines) == 0 {
+			continue
+		}
+
+		var entry FileHistoryEntry
+		if entry, err = parseFileHistoryEntry(lines); err != nil {
+			return
+		}
+
+		// NOTE: merges are listed without changes
+		if entry.Change == "" {
+			continue
+		}
+
+		list = append(list, entry)
+		hashes = append(hashes, entry.Hash)
+	}
+
+	if len(hashes) == 0 {
+		return
+	}
+
+	logArgs := []string{"log", "--no-walk=unsorted"}
+	logArgs = append(logArgs, hashes...)
+
+	entries, err := h.logEntries(logArgs...)
+	if err != nil {
+		return
+	}
+
+	byHash := make(map[string]LogEntry, len(entries))
+	for _, e := range entries {
+		byHash[e.Hash] = e
+	}
+	for i := range list {
+		list[i].LogEntry = byHash[list[i].Hash]
+	}
+
+	return
+}
+
+// parseFileHistoryEntry parses a commit's hash, followed by its `--raw`
+// and `--numstat` lines for a single file.
+func parseFileHistoryEntry(lines []string) (entry FileHistoryEntry, err error) {
+	entry.Hash = strings.TrimSpace(lines[0])
+
+	for _, l := range lines[1:] {
+		if l == "" {
+			continue
+		}
+
+		// :oldmode newmode oldblob newblob status TAB path [TAB newpath]
+		if strings.HasPrefix(l, ":") {
+			if entry.Change != "" {
+				continue
+			}
+
+			meta, paths, found := strings.Cut(l, "\t")
+			f := strings.Fields(meta)
+			if !found || len(f) != 5 || f[4] == "" {
+				err = fmt.Errorf("unexpected raw diff line: %q", l)
+				return
+			}
+
+			change, ok := fileChangeStatus[f[4][0]]
+			if !ok {
+				err = fmt.Errorf("unsupported file status: %q", f[4])
+				return
+			}
+			entry.Change = change
+
+			if strings.Trim(f[3], "0") != "" {
+				entry.Blob = f[3]
+			}
+
+			entry.Path = paths
+			if oldPath, newPath, found := strings.Cut(paths, "\t"); found {
+				entry.OldPath, entry.Path = oldPath, newPath
+			}
+			continue
+		}
+
+		// added TAB deleted TAB path
+		f := strings.SplitN(l, "\t", 3)
+		if len(f) != 3 || entry.Added != 0 || entry.Deleted != 0 || entry.Binary {
+			continue
+		}
+
+		if f[0] == "-" && f[1] == "-" {
+			entry.Binary = true
+			continue
+		}
+
+		if entry.Added, err = strconv.Atoi(f[0]); err != nil {
+			return
+		}
+		if entry.Deleted, err = strconv.Atoi(f[1]); err != nil {
+			return
+		}
+	}
+
+	return
+}
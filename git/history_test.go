@@ -0,0 +1,82 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestFileHistory(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	write := func(name, content string) {
+		err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		assert.NoError(t, err)
+	}
+
+	write("app.conf", "port=80\nhost=localhost\nmode=dev\n")
+	write("other.txt", "other\n")
+	err := g.CommitFiles([]string{"app.conf", "other.txt"}, "Add config")
+	assert.NoError(t, err)
+
+	write("app.conf", "port=8080\nhost=localhost\nmode=dev\n")
+	err = g.CommitFiles([]string{"app.conf"}, "Change port")
+	assert.NoError(t, err)
+
+	write("other.txt", "changed\n")
+	err = g.CommitFiles([]string{"other.txt"}, "Unrelated change")
+	assert.NoError(t, err)
+
+	err = os.Mkdir(filepath.Join(dir, "conf"), 0755)
+	assert.NoError(t, err)
+	_, err = g.(*handlerImpl).execute("mv", "app.conf", "conf/app.conf")
+	assert.NoError(t, err)
+	err = g.Commit("Move config")
+	assert.NoError(t, err)
+
+	write("conf/app.conf", "port=8080\nhost=localhost\nmode=prod\n")
+	err = g.CommitFiles([]string{"conf/app.conf"}, "Switch to prod")
+	assert.NoError(t, err)
+
+	list, err := g.FileHistory("conf/app.conf", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(list))
+
+	assert.Equal(t, "Switch to prod", list[0].Subject)
+	assert.Equal(t, FileModified, list[0].Change)
+	assert.Equal(t, "conf/app.conf", list[0].Path)
+	assert.Equal(t, 1, list[0].Added)
+	assert.Equal(t, 1, list[0].Deleted)
+
+	assert.Equal(t, "Move config", list[1].Subject)
+	assert.Equal(t, FileRenamed, list[1].Change)
+	assert.Equal(t, "app.conf", list[1].OldPath)
+	assert.Equal(t, "conf/app.conf", list[1].Path)
+	assert.Equal(t, 0, list[1].Added)
+
+	assert.Equal(t, "app.conf", list[2].Path)
+	assert.Equal(t, FileModified, list[2].Change)
+
+	assert.Equal(t, FileAdded, list[3].Change)
+	assert.Equal(t, 3, list[3].Added)
+
+	content, err := g.FileAt(list[2].Hash, list[2].Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "port=8080\nhost=localhost\nmode=dev\n", string(content))
+
+	content, err = g.FileAt("", "conf/app.conf")
+	assert.NoError(t, err)
+	assert.Equal(t, "port=8080\nhost=localhost\nmode=prod\n", string(content))
+
+	list, err = g.FileHistory("conf/app.conf", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(list))
+
+	_, err = g.FileAt("HEAD", "missing.txt")
+	assert.Error(t, err)
+}
@@ -0,0 +1,325 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// staleLockAge is the age after which a lock file is considered stale.
+const staleLockAge = 10 * time.Minute
+
+// GCOptions defines the options supported by GC.
+type GCOptions struct {
+	// Auto only runs if there is too much housekeeping to do.
+	Auto bool `json:"auto,omitempty"`
+
+	// Aggressive optimizes the repository more thoroughly, at the cost of time.
+	Aggressive bool `json:"aggressive,omitempty"`
+
+	// Prune overrides the expiry for loose objects, e.g. `now` or `2.weeks.ago`.
+	Prune string `json:"prune,omitempty"`
+}
+
+// ObjectCounts defines the object statistics reported by `git count-objects -v`.
+// Sizes are in bytes.
+type ObjectCounts struct {
+	Loose         int64 `json:"loose"`
+	LooseSize     int64 `json:"looseSize"`
+	InPack        int64 `json:"inPack"`
+	Packs         int64 `json:"packs"`
+	PackSize      int64 `json:"packSize"`
+	PrunePackable int64 `json:"prunePackable"`
+	Garbage       int64 `json:"garbage"`
+	GarbageSize   int64 `json:"garbageSize"`
+}
+
+// FsckFinding defines an issue reported by `git fsck`.
+type FsckFinding struct {
+	// Kind is one of `dangling`, `unreachable`, `missing`, `broken link`,
+	// `error`, `warning` or `notice`.
+	Kind       string `json:"kind"`
+	ObjectType string `json:"objectType,omitempty"`
+	Hash       string `json:"hash,omitempty"`
+	Message    string `json:"message,omitempty"`
+}
+
+// PackInfo defines a packfile in the repository.
+type PackInfo struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+}
+
+// HealthReport summarizes the state of a repository.
+type HealthReport struct {
+	Objects ObjectCounts `json:"objects"`
+	Packs   []PackInfo   `json:"packs"`
+
+	// Dangling are the objects not referenced by any other.
+	Dangling []FsckFinding `json:"dangling"`
+
+	// Problems are the remaining fsck findings, e.g. missing objects,
+	// notices excluded.
+	Problems []FsckFinding `json:"problems"`
+
+	// StaleLocks are the lock files, relative to the git directory,
+	// left behind for longer than a git command would normally take.
+	StaleLocks []string `json:"staleLocks"`
+
+	Shallow bool `json:"shallow"`
+}
+
+// IsHealthy returns true if there are no problems nor stale locks.
+func (r *HealthReport) IsHealthy() bool {
+	return len(r.Problems) == 0 && len(r.StaleLocks) == 0
+}
+
+func (h *handlerImpl) CountObjects() (counts ObjectCounts, err error) {
+	h.log.Info("Counting objects")
+
+	out, err := h.execute("count-objects", "--verbose")
+	if err != nil {
+		return
+	}
+
+	fields := map[string]*int64{
+		"count":          &counts.Loose,
+		"size":           &counts.LooseSize,
+		"in-pack":        &counts.InPack,
+		"packs":          &counts.Packs,
+		"size-pack":      &counts.PackSize,
+		"prune-packable": &counts.PrunePackable,
+		"garbage":        &counts.Garbage,
+		"size-garbage":   &counts.GarbageSize,
+	}
+
+	for _, l := range splitLines(out) {
+		k, v, found := strings.Cut(l, ": ")
+		if !found {
+			continue
+		}
+
+		p, ok := fields[k]
+		if !ok {
+			continue
+		}
+
+		if *p, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return
+		}
+
+		// NOTE: sizes are reported in KiB
+		if strings.HasPrefix(k, "size") {
+			*p *= 1024
+		}
+	}
+
+	return
+}
+
+func (h *handlerImpl) Fsck(unreachable ...bool) ([]FsckFinding, error) {
+	h.log.Info("Checking repository integrity", "unreachable", unreachable)
+
+	args := []string{"fsck", "--no-progress"}
+	if len(unreachable) > 0 && unreachable[0] {
+		args = append(args, "--unreachable")
+	}
+
+	return h.fsck(args...)
+}
+
+func (h *handlerImpl) GC(opts GCOptions) error {
+	h.log.With(
+		"auto", opts.Auto,
+		"aggressive", opts.Aggressive,
+		"prune", opts.Prune,
+	).Info("Collecting garbage")
+
+	args := []string{"gc", "--quiet"}
+	if opts.Auto {
+		args = append(args, "--auto")
+	}
+	if opts.Aggressive {
+		args = append(args, "--aggressive")
+	}
+	if opts.Prune != "" {
+		args = append(args, "--prune="+opts.Prune)
+	}
+
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) Health() (report HealthReport, err error) {
+	h.log.Info("Checking repository health")
+
+	if report.Objects, err = h.CountObjects(); err != nil {
+		return
+	}
+
+	out, err := h.execute("rev-parse", "--is-shallow-repository")
+	if err != nil {
+		return
+	}
+	report.Shallow = strings.TrimSpace(string(out)) == "true"
+
+	dir, err := h.gitDir()
+	if err != nil {
+		return
+	}
+
+	report.Packs = []PackInfo{}
+	packs, _ := filepath.Glob(filepath.Join(dir, "objects", "pack", "*.pack"))
+	for _, p := range packs {
+		if fi, serr := os.Stat(p); serr == nil {
+			report.Packs = append(report.Packs, PackInfo{Name: filepath.Base(p), Size: fi.Size()})
+		}
+	}
+
+	report.StaleLocks = []string{}
+	now := time.Now()
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, werr error) error {
+		if werr != nil || d.IsDir() || !strings.HasSuffix(d.Name(), ".lock") {
+			return nil
+		}
+
+		info, ierr := d.Info()
+		if ierr != nil || now.Sub(info.ModTime()) < staleLockAge {
+			return nil
+		}
+
+		rel, rerr := filepath.Rel(dir, path)
+		if rerr != nil {
+			rel = path
+		}
+		report.StaleLocks = append(report.StaleLocks, rel)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	findings, err := h.fsck("fsck", "--no-progress", "--connectivity-only")
+	if err != nil {
+		return
+	}
+
+	report.Dangling = []FsckFinding{}
+	report.Problems = []FsckFinding{}
+	for _, f := range findings {
+		switch f.Kind {
+		case "dangling":
+			report.Dangling = append(report.Dangling, f)
+		case "notice":
+		default:
+			report.Problems = append(report.Problems, f)
+		}
+	}
+
+	return
+}
+
+func (h *handlerImpl) Prune(expire string) error {
+	h.log.Info("Pruning unreachable objects", "expire", expire)
+
+	args := []string{"prune"}
+	if expire != "" {
+		args = append(args, "--expire", expire)
+	}
+
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) Repack(all ...bool) error {
+	h.log.Info("Repacking objects", "all", all)
+
+	args := []string{"repack", "-d", "--quiet"}
+	if len(all) > 0 && all[0] {
+		args = append(args, "-a")
+	}
+
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) WriteCommitGraph() error {
+	h.log.Info("Writing commit-graph")
+
+	return h.executeNO("commit-graph", "write", "--reachable")
+}
+
+// fsck runs the given fsck command and parses its findings. Since fsck
+// exits with an error when it finds problems, such an exit is not
+// reported as an error, as long as findings are returned.
+func (h *handlerImpl) fsck(in ...string) ([]FsckFinding, error) {
+	errb := &bytes.Buffer{}
+
+	out, err := h.run(runOptions{stderr: errb}, in...)
+
+	findings := parseFsckFindings(append(out, errb.Bytes()...))
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || len(findings) == 0 {
+			return nil, err
+		}
+	}
+
+	return findings, nil
+}
+
+// parseFsckFindings parses the output of `git fsck`.
+func parseFsckFindings(out []byte) []FsckFinding {
+	list := []FsckFinding{}
+	for _, l := range splitLines(out) {
+		trimmed := strings.TrimSpace(l)
+
+		// NOTE: broken links span two lines, the second one starting with `to`
+		if rest, ok := strings.CutPrefix(trimmed, "to "); ok && len(list) > 0 &&
+			list[len(list)-1].Kind == "broken link" {
+			list[len(list)-1].Message = "to " + strings.Join(strings.Fields(rest), " ")
+			continue
+		}
+
+		if rest, ok := strings.CutPrefix(trimmed, "broken link from "); ok {
+			f := strings.Fields(rest)
+			finding := FsckFinding{Kind: "broken link"}
+			if len(f) >= 2 {
+				finding.ObjectType, finding.Hash = f[0], f[1]
+			}
+			list = append(list, finding)
+			continue
+		}
+
+		f := strings.Fields(trimmed)
+		if len(f) == 3 {
+			switch f[0] {
+			case "dangling", "unreachable", "missing":
+				list = append(list, FsckFinding{Kind: f[0], ObjectType: f[1], Hash: f[2]})
+				continue
+			}
+		}
+
+		kind, rest, found := strings.Cut(trimmed, " in ")
+		if found && (kind == "error" || kind == "warning") {
+			obj, msg, _ := strings.Cut(rest, ": ")
+			of := strings.Fields(obj)
+			finding := FsckFinding{Kind: kind, Message: msg}
+			if len(of) == 2 {
+				finding.ObjectType, finding.Hash = of[0], of[1]
+			}
+			list = append(list, finding)
+			continue
+		}
+
+		kind, msg, found := strings.Cut(trimmed, ": ")
+		if found && (kind == "error" || kind == "warning" || kind == "notice") {
+			list = append(list, FsckFinding{Kind: kind, Message: msg})
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,108 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestHealth(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := "file.txt"
+	err := os.WriteFile(filepath.Join(dir, file), []byte("test"), 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	counts, err := g.CountObjects()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), counts.Loose)
+	assert.Equal(t, int64(0), counts.Packs)
+
+	out, err := exec.Command("git", "hash-object", "-w", "--stdin").Output()
+	assert.NoError(t, err)
+	dangling := strings.TrimSpace(string(out))
+
+	findings, err := g.Fsck()
+	assert.NoError(t, err)
+	assert.Equal(t, []FsckFinding{{Kind: "dangling", ObjectType: "blob", Hash: dangling}}, findings)
+
+	lock := filepath.Join(dir, ".git", "index.lock")
+	err = os.WriteFile(lock, []byte{}, 0644)
+	assert.NoError(t, err)
+
+	old := time.Now().Add(-time.Hour)
+	err = os.Chtimes(lock, old, old)
+	assert.NoError(t, err)
+
+	report, err := g.Health()
+	assert.NoError(t, err)
+	assert.Equal(t, false, report.IsHealthy())
+	assert.Equal(t, []string{"index.lock"}, report.StaleLocks)
+	assert.Equal(t, 1, len(report.Dangling))
+	assert.Equal(t, false, report.Shallow)
+
+	err = os.Remove(lock)
+	assert.NoError(t, err)
+
+	err = g.GC(GCOptions{Prune: "now"})
+	assert.NoError(t, err)
+
+	err = g.WriteCommitGraph()
+	assert.NoError(t, err)
+
+	report, err = g.Health()
+	assert.NoError(t, err)
+	assert.Equal(t, true, report.IsHealthy())
+	assert.Equal(t, 0, len(report.Dangling))
+	assert.Equal(t, int64(0), report.Objects.Loose)
+	assert.Equal(t, int64(1), report.Objects.Packs)
+	assert.Equal(t, 1, len(report.Packs))
+	assert.Equal(t, true, report.Packs[0].Size > 0)
+
+	err = g.Repack(true)
+	assert.NoError(t, err)
+
+	err = g.Prune("now")
+	assert.NoError(t, err)
+}
+
+func TestParseFsckFindings(t *testing.T) {
+	out := `dangling commit 1111111111111111111111111111111111111111
+missing blob 2222222222222222222222222222222222222222
+broken link from    tree 3333333333333333333333333333333333333333
+              to    blob 2222222222222222222222222222222222222222
+error in tree 3333333333333333333333333333333333333333: badTree: some problem
+error: refs/heads/broken: invalid sha1 pointer 0000000000000000000000000000000000000000
+notice: HEAD points to an unborn branch (main)
+`
+
+	expected := []FsckFinding{
+		{Kind: "dangling", ObjectType: "commit", Hash: "1111111111111111111111111111111111111111"},
+		{Kind: "missing", ObjectType: "blob", Hash: "2222222222222222222222222222222222222222"},
+		{
+			Kind:       "broken link",
+			ObjectType: "tree",
+			Hash:       "3333333333333333333333333333333333333333",
+			Message:    "to blob 2222222222222222222222222222222222222222",
+		},
+		{
+			Kind:       "error",
+			ObjectType: "tree",
+			Hash:       "3333333333333333333333333333333333333333",
+			Message:    "badTree: some problem",
+		},
+		{Kind: "error", Message: "refs/heads/broken: invalid sha1 pointer 0000000000000000000000000000000000000000"},
+		{Kind: "notice", Message: "HEAD points to an unborn branch (main)"},
+	}
+
+	assert.Equal(t, expected, parseFsckFindings([]byte(out)))
+}
@@ -0,0 +1,1069 @@
+package git
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jwmwalrus/bnp/onerror"
+)
+
+// ErrNotSupported is returned by the native handler for the operations
+// that require the git command, e.g. those modifying the repository.
+var ErrNotSupported = errors.New("operation not supported without the git command")
+
+// nativeCommitCacheSize bounds the number of parsed commits kept by the
+// native handler, to speed up repeated history walks.
+const nativeCommitCacheSize = 4096
+
+// minAbbrev and defaultAbbrev are the minimum and default lengths of
+// abbreviated hashes, as defined by git.
+const (
+	minAbbrev     = 4
+	defaultAbbrev = 7
+)
+
+// NewNativeHandler returns a read-only git interface for the given
+// directory, which reads the repository directly instead of running the
+// git command. Operations it does not support return ErrNotSupported.
+func NewNativeHandler(dir string) (Handler, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	root, gitDir, err := findGitDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	commonDir := gitDir
+	if b, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = strings.TrimSpace(string(b))
+		if !filepath.IsAbs(commonDir) {
+			commonDir = filepath.Join(gitDir, commonDir)
+		}
+	}
+
+	hashLen := 20
+	if b, err := os.ReadFile(filepath.Join(commonDir, "config")); err == nil {
+		for _, l := range splitLines(b) {
+			k, v, found := strings.Cut(strings.ToLower(strings.ReplaceAll(l, " ", "")), "=")
+			if found && k == "objectformat" && v == "sha256" {
+				hashLen = 32
+			}
+		}
+	}
+
+	objects, err := newObjectStore(filepath.Join(commonDir, "objects"), hashLen)
+	if err != nil {
+		return nil, err
+	}
+
+	return &nativeHandler{
+		root:    root,
+		log:     slog.Default().WithGroup("git").With("backend", "native"),
+		refs:    &refStore{gitDir: gitDir, commonDir: commonDir},
+		objects: objects,
+		commits: map[string]*nativeCommit{},
+	}, nil
+}
+
+// findGitDir looks for the repository containing dir, and returns its
+// top-level directory and git directory. The latter is the top-level
+// directory itself for bare repositories.
+func findGitDir(dir string) (root, gitDir string, err error) {
+	for d := dir; ; d = filepath.Dir(d) {
+		dotGit := filepath.Join(d, ".git")
+		if fi, serr := os.Stat(dotGit); serr == nil {
+			if fi.IsDir() {
+				return d, dotGit, nil
+			}
+
+			// NOTE: linked worktrees and submodules have a `gitdir: <path>` file
+			b, rerr := os.ReadFile(dotGit)
+			if rerr != nil {
+				return "", "", rerr
+			}
+			target, ok := strings.CutPrefix(strings.TrimSpace(string(b)), "gitdir: ")
+			if !ok {
+				return "", "", fmt.Errorf("invalid gitdir file: %s", dotGit)
+			}
+			if !filepath.IsAbs(target) {
+				target = filepath.Join(d, target)
+			}
+			return d, target, nil
+		}
+
+		if isBareGitDir(d) {
+			return d, d, nil
+		}
+
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+
+	return "", "", fmt.Errorf("not a git repository (or any of the parent directories): %s", dir)
+}
+
+func isBareGitDir(dir string) bool {
+	for _, name := range []string{"HEAD", "objects", "refs"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// nativeHandler implements the Handler interface by reading the
+// repository directly. It is read-only.
+type nativeHandler struct {
+	root    string
+	log     *slog.Logger
+	refs    *refStore
+	objects *objectStore
+
+	mu      sync.Mutex
+	commits map[string]*nativeCommit
+}
+
+// nativeCommit defines a parsed commit object.
+type nativeCommit struct {
+	Hash       string
+	Tree       string
+	Parents    []string
+	Author     string
+	Email      string
+	AuthorTime int64
+	CommitTime int64
+	Message    string
+}
+
+func (c *nativeCommit) logEntry() LogEntry {
+	subject, body := splitCommitMessage(c.Message)
+	return LogEntry{
+		Hash:      c.Hash,
+		Timestamp: time.Unix(c.AuthorTime, 0),
+		Author:    c.Author,
+		Email:     c.Email,
+		Subject:   subject,
+		Body:      body,
+	}
+}
+
+// NOTE: the native handler never runs git, nor modifies the repository,
+// so there are no invocations to observe, skip, audit or configure.
+
+func (h *nativeHandler) AddObserver(obs CommandObserver) {}
+
+func (h *nativeHandler) SetAuditFile(file string) error {
+	return nil
+}
+
+func (h *nativeHandler) SetDryRun(dryRun bool) {}
+
+func (h *nativeHandler) SetEnvOptions(opts EnvOptions) error {
+	return nil
+}
+
+func (h *nativeHandler) Branch() (name string, err error) {
+	h.log.Info("Returning active branch")
+
+	head, ok, err := h.refs.read("HEAD")
+	if err != nil || !ok {
+		return
+	}
+
+	name, _ = strings.CutPrefix(head.Target, "refs/heads/")
+	return
+}
+
+func (h *nativeHandler) Branches(all ...bool) (list []string, err error) {
+	h.log.Info("Returning list of branches", "all", all)
+
+	head, ok, err := h.refs.read("HEAD")
+	if err != nil {
+		return
+	}
+	if ok && head.Target == "" {
+		var detached string
+		if detached, err = h.detachedHead(head.Hash); err != nil {
+			return
+		}
+		list = append(list, detached)
+	}
+
+	refs, err := h.refs.list("refs/heads/")
+	if err != nil {
+		return
+	}
+	for _, r := range refs {
+		list = append(list, strings.TrimPrefix(r.Name, "refs/heads/"))
+	}
+
+	if len(all) == 0 || !all[0] {
+		return
+	}
+
+	if refs, err = h.refs.list("refs/remotes/"); err != nil {
+		return
+	}
+	for _, r := range refs {
+		name := "remotes/" + strings.TrimPrefix(r.Name, "refs/remotes/")
+		if r.Target != "" {
+			name += " -> " + strings.TrimPrefix(r.Target, "refs/remotes/")
+		}
+		list = append(list, name)
+	}
+
+	return
+}
+
+func (h *nativeHandler) Close() error {
+	h.log.Info("Closing pack files")
+
+	return h.objects.close()
+}
+
+func (h *nativeHandler) Describe(hash string, exact ...bool) (tag string, err error) {
+	h.log.With(
+		"hash", hash,
+		"exact", exact,
+	).Info("Describing current tree state")
+
+	if hash == "" {
+		hash = "HEAD"
+	}
+
+	target, err := h.resolveCommit(hash)
+	if err != nil {
+		return
+	}
+
+	// NOTE: like git, only annotated tags are considered
+	tags, err := h.commitTags(true)
+	if err != nil {
+		return
+	}
+
+	if names, ok := tags[target]; ok {
+		tag = names[0]
+		return
+	}
+
+	if len(exact) > 0 && exact[0] {
+		err = fmt.Errorf("no tag exactly matches %s", target)
+		return
+	}
+
+	// NOTE: like git, the best of the first 10 candidates is chosen,
+	// i.e. the one with the fewest commits since
+	const maxCandidates = 10
+	var candidates []string
+	err = h.walk([]string{target}, func(c *nativeCommit) bool {
+		if _, ok := tags[c.Hash]; ok {
+			candidates = append(candidates, c.Hash)
+		}
+		return len(candidates) < maxCandidates
+	})
+	if err != nil {
+		return
+	}
+
+	if len(candidates) == 0 {
+		err = fmt.Errorf("no annotated tags can describe %s", target)
+		return
+	}
+
+	best, bestDepth := "", -1
+	for _, c := range candidates {
+		var depth int
+		if depth, err = h.countExclusive(target, c); err != nil {
+			return
+		}
+		if bestDepth < 0 || depth < bestDepth {
+			best, bestDepth = c, depth
+		}
+	}
+
+	short, err := h.abbrev(target, 0)
+	if err != nil {
+		return
+	}
+
+	tag = fmt.Sprintf("%s-%d-g%s", tags[best][0], bestDepth, short)
+	return
+}
+
+func (h *nativeHandler) FileAt(rev, path string) ([]byte, error) {
+	h.log.With(
+		"rev", rev,
+		"path", path,
+	).Info("Getting file contents")
+
+	if rev == "" {
+		rev = "HEAD"
+	}
+
+	commit, err := h.resolveCommit(rev)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := h.commit(commit)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, isTree := c.Tree, true
+	for _, name := range strings.Split(strings.Trim(filepath.ToSlash(path), "/"), "/") {
+		if !isTree {
+			return nil, fmt.Errorf("path %q does not exist in %s", path, rev)
+		}
+
+		var tree []byte
+		if tree, err = h.objects.readTyped(hash, objectTree); err != nil {
+			return nil, err
+		}
+
+		var ok bool
+		if hash, isTree, ok = findTreeEntry(tree, name, h.objects.hashLen); !ok {
+			return nil, fmt.Errorf("path %q does not exist in %s", path, rev)
+		}
+	}
+
+	if isTree {
+		return nil, fmt.Errorf("path %q is a directory in %s", path, rev)
+	}
+
+	return h.objects.readTyped(hash, objectBlob)
+}
+
+func (h *nativeHandler) IsAncestor(ancestor, rev string) (bool, error) {
+	h.log.With(
+		"ancestor", ancestor,
+		"rev", rev,
+	).Info("Checking ancestry")
+
+	a, err := h.resolveCommit(ancestor)
+	if err != nil {
+		return false, err
+	}
+
+	r, err := h.resolveCommit(rev)
+	if err != nil {
+		return false, err
+	}
+
+	found := false
+	err = h.walk([]string{r}, func(c *nativeCommit) bool {
+		found = c.Hash == a
+		return !found
+	})
+	return found, err
+}
+
+func (h *nativeHandler) LatestHash(noFetch ...bool) (hash string, err error) {
+	h.log.Info("Getting latest hash")
+
+	return h.resolve("HEAD")
+}
+
+func (h *nativeHandler) LatestTag(noFetch ...bool) (tag string, err error) {
+	h.log.Info("Getting latest tag", "no-fetch", noFetch)
+
+	if len(noFetch) == 0 || !noFetch[0] {
+		h.log.Warn("Fetching is not supported by the native backend, using local tags")
+	}
+
+	tags, err := h.commitTags(false)
+	if err != nil {
+		return
+	}
+
+	var latest *nativeCommit
+	for hash := range tags {
+		var c *nativeCommit
+		if c, err = h.commit(hash); err != nil {
+			return
+		}
+		if latest == nil || c.CommitTime > latest.CommitTime ||
+			(c.CommitTime == latest.CommitTime && tags[c.Hash][0] < tags[latest.Hash][0]) {
+			latest = c
+		}
+	}
+
+	if latest == nil {
+		err = errors.New("no tags found")
+		return
+	}
+
+	tag = tags[latest.Hash][0]
+	return
+}
+
+func (h *nativeHandler) Log(maxCount int) (list []LogEntry, err error) {
+	h.log.Info("Getting log", "max-count", maxCount)
+
+	head, err := h.resolveCommit("HEAD")
+	if err != nil {
+		return
+	}
+
+	list = []LogEntry{}
+	err = h.walk([]string{head}, func(c *nativeCommit) bool {
+		// NOTE: as with the exec handler, whose output is line-based,
+		// only the first line of the body is kept
+		e := c.logEntry()
+		e.Body, _, _ = strings.Cut(e.Body, "\n")
+		list = append(list, e)
+		return maxCount <= 0 || len(list) < maxCount
+	})
+	return
+}
+
+func (h *nativeHandler) MustMoveToRootDir() RestoreCwdFunc {
+	cwd, err := os.Getwd()
+	onerror.Fatal(err)
+
+	if cwd == h.root {
+		return func() error { return nil }
+	}
+
+	onerror.Fatal(os.Chdir(h.root))
+
+	return func() error { return os.Chdir(cwd) }
+}
+
+func (h *nativeHandler) ResolveRev(rev string) (hash string, err error) {
+	h.log.Info("Resolving revision", "rev", rev)
+
+	if hash, err = h.resolveCommit(rev); err != nil {
+		err = fmt.Errorf("unable to resolve revision %q: %w", rev, err)
+	}
+	return
+}
+
+func (h *nativeHandler) RevListCount(from, to string) (int, error) {
+	h.log.With(
+		"from", from,
+		"to", to,
+	).Info("Counting commits")
+
+	t, err := h.resolveCommit(to)
+	if err != nil {
+		return 0, err
+	}
+
+	if from == "" {
+		count := 0
+		err = h.walk([]string{t}, func(*nativeCommit) bool {
+			count++
+			return true
+		})
+		return count, err
+	}
+
+	f, err := h.resolveCommit(from)
+	if err != nil {
+		return 0, err
+	}
+
+	return h.countExclusive(t, f)
+}
+
+func (h *nativeHandler) ShortHash(rev string, length ...int) (hash string, err error) {
+	h.log.With(
+		"rev", rev,
+		"length", length,
+	).Info("Abbreviating hash")
+
+	full, err := h.resolve(rev)
+	if err != nil {
+		return
+	}
+
+	minLen := 0
+	if len(length) > 0 && length[0] > 0 {
+		minLen = max(length[0], minAbbrev)
+	}
+
+	return h.abbrev(full, minLen)
+}
+
+func (h *nativeHandler) SymbolicRef(name string) (ref string, err error) {
+	h.log.Info("Reading symbolic reference", "name", name)
+
+	r, ok, err := h.refs.read(name)
+	if err != nil {
+		return
+	}
+	if !ok || r.Target == "" {
+		err = fmt.Errorf("ref %s is not a symbolic ref", name)
+		return
+	}
+
+	ref = r.Target
+	return
+}
+
+func (h *nativeHandler) TopLevel() string {
+	h.log.Info("Returning top level")
+
+	return h.root
+}
+
+// abbrev returns the shortest unique abbreviation of the given hash,
+// with at least minLen characters, or the default length if minLen is 0.
+func (h *nativeHandler) abbrev(hash string, minLen int) (string, error) {
+	if minLen <= 0 {
+		// NOTE: like git, the length grows with the number of packed objects
+		var count uint
+		h.objects.mu.Lock()
+		for _, p := range h.objects.packs {
+			count += uint(p.fanout[255])
+		}
+		h.objects.mu.Unlock()
+
+		minLen = max((bits.Len(count)+1)/2, defaultAbbrev)
+	}
+
+	for n := minLen; n < len(hash); n++ {
+		list, err := h.objects.withPrefix(hash[:n], 2)
+		if err != nil {
+			return "", err
+		}
+		if len(list) <= 1 {
+			return hash[:n], nil
+		}
+	}
+	return hash, nil
+}
+
+// commit returns the parsed commit with the given hash.
+func (h *nativeHandler) commit(hash string) (*nativeCommit, error) {
+	h.mu.Lock()
+	c, ok := h.commits[hash]
+	h.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	data, err := h.objects.readTyped(hash, objectCommit)
+	if err != nil {
+		return nil, err
+	}
+
+	if c, err = parseCommit(hash, data); err != nil {
+		return nil, err
+	}
+
+	h.mu.Lock()
+	if len(h.commits) >= nativeCommitCacheSize {
+		clear(h.commits)
+	}
+	h.commits[hash] = c
+	h.mu.Unlock()
+
+	return c, nil
+}
+
+// commitTags maps commits to the names of the tags pointing to them,
+// the preferred one first: annotated over lightweight tags, the most
+// recent annotated one first, and by name otherwise.
+func (h *nativeHandler) commitTags(annotatedOnly bool) (map[string][]string, error) {
+	refs, err := h.refs.list("refs/tags/")
+	if err != nil {
+		return nil, err
+	}
+
+	type tagInfo struct {
+		name      string
+		annotated bool
+		date      int64
+	}
+
+	infos := map[string][]tagInfo{}
+	for _, r := range refs {
+		if r.Hash == "" {
+			continue
+		}
+
+		info := tagInfo{name: strings.TrimPrefix(r.Name, "refs/tags/")}
+		hash := r.Hash
+
+		typ, data, err := h.objects.read(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if typ == objectTag {
+			info.annotated = true
+			info.date = parseTaggerTime(data)
+		} else if annotatedOnly {
+			continue
+		}
+
+		if hash, err = h.peel(hash); err != nil {
+			// NOTE: tags pointing to trees or blobs cannot describe commits
+			continue
+		}
+
+		infos[hash] = append(infos[hash], info)
+	}
+
+	tags := make(map[string][]string, len(infos))
+	for hash, list := range infos {
+		best := 0
+		for i, t := range list[1:] {
+			b := list[best]
+			if t.annotated && (!b.annotated || t.date > b.date) {
+				best = i + 1
+			}
+		}
+
+		names := []string{list[best].name}
+		for i, t := range list {
+			if i != best {
+				names = append(names, t.name)
+			}
+		}
+		tags[hash] = names
+	}
+
+	return tags, nil
+}
+
+// countExclusive returns the number of commits reachable from include
+// but not from exclude.
+func (h *nativeHandler) countExclusive(include, exclude string) (int, error) {
+	excluded := map[string]bool{}
+	err := h.walk([]string{exclude}, func(c *nativeCommit) bool {
+		excluded[c.Hash] = true
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = h.walkExcluding([]string{include}, excluded, func(*nativeCommit) bool {
+		count++
+		return true
+	})
+	return count, err
+}
+
+// peel follows tag objects until a commit is reached.
+func (h *nativeHandler) peel(hash string) (string, error) {
+	for range maxSymrefDepth * 2 {
+		typ, data, err := h.objects.read(hash)
+		if err != nil {
+			return "", err
+		}
+
+		switch typ {
+		case objectCommit:
+			return hash, nil
+		case objectTag:
+			obj, _, _ := strings.Cut(string(data), "\n")
+			var ok bool
+			if hash, ok = strings.CutPrefix(obj, "object "); !ok {
+				return "", fmt.Errorf("invalid tag object %s", hash)
+			}
+		default:
+			return "", fmt.Errorf("object %s is a %s, not a commit", hash, typ)
+		}
+	}
+	return "", fmt.Errorf("too many levels of tags: %s", hash)
+}
+
+// resolve returns the object named by the given revision, supporting
+// hashes, abbreviated hashes, reference names, and the `~N`, `^N` and
+// `^{}` suffixes.
+func (h *nativeHandler) resolve(rev string) (hash string, err error) {
+	base := rev
+	suffix := ""
+	if i := strings.IndexAny(rev, "~^"); i >= 0 {
+		base, suffix = rev[:i], rev[i:]
+	}
+
+	if hash, err = h.resolveBase(base); err != nil {
+		return
+	}
+
+	for suffix != "" {
+		op := suffix[0]
+		suffix = suffix[1:]
+
+		if op == '^' && strings.HasPrefix(suffix, "{") {
+			peel, rest, found := strings.Cut(suffix[1:], "}")
+			if !found || (peel != "" && peel != "commit") {
+				err = fmt.Errorf("unsupported revision: %q", rev)
+				return
+			}
+			suffix = rest
+			if hash, err = h.peel(hash); err != nil {
+				return
+			}
+			continue
+		}
+
+		digits := len(suffix) - len(strings.TrimLeft(suffix, "0123456789"))
+		n := 1
+		if digits > 0 {
+			if n, err = strconv.Atoi(suffix[:digits]); err != nil {
+				return
+			}
+			suffix = suffix[digits:]
+		}
+
+		if hash, err = h.peel(hash); err != nil {
+			return
+		}
+
+		if op == '~' {
+			for range n {
+				if hash, err = h.parent(hash, 1, rev); err != nil {
+					return
+				}
+			}
+			continue
+		}
+
+		if n > 0 {
+			if hash, err = h.parent(hash, n, rev); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func (h *nativeHandler) resolveBase(name string) (string, error) {
+	if name == "" || name == "@" {
+		name = "HEAD"
+	}
+
+	if isHex(name) && len(name) == 2*h.objects.hashLen {
+		if _, _, err := h.objects.read(strings.ToLower(name)); err != nil {
+			return "", err
+		}
+		return strings.ToLower(name), nil
+	}
+
+	if _, hash, ok, err := h.dwimRef(name); err != nil || ok {
+		return hash, err
+	}
+
+	if isHex(name) && len(name) >= minAbbrev {
+		list, err := h.objects.withPrefix(name, 2)
+		if err != nil {
+			return "", err
+		}
+		switch len(list) {
+		case 1:
+			return list[0], nil
+		case 2:
+			return "", fmt.Errorf("short object ID %s is ambiguous", name)
+		}
+	}
+
+	return "", fmt.Errorf("unknown revision: %q", name)
+}
+
+// dwimRef returns the reference the given name stands for, along with
+// the object it points to.
+func (h *nativeHandler) dwimRef(name string) (ref, hash string, ok bool, err error) {
+	// NOTE: same lookup order as git-rev-parse(1)
+	candidates := []string{
+		"refs/" + name,
+		"refs/tags/" + name,
+		"refs/heads/" + name,
+		"refs/remotes/" + name,
+		"refs/remotes/" + name + "/HEAD",
+	}
+	if strings.HasPrefix(name, "refs/") || strings.ToUpper(name) == name {
+		candidates = append([]string{name}, candidates...)
+	}
+
+	for _, ref = range candidates {
+		if hash, ok, err = h.refs.resolve(ref); err != nil || (ok && hash != "") {
+			return
+		}
+	}
+
+	ref, hash, ok = "", "", false
+	return
+}
+
+// detachedHead describes a detached HEAD as git-branch(1) does, i.e.
+// based on the last checkout recorded in the HEAD reflog.
+func (h *nativeHandler) detachedHead(head string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(h.refs.gitDir, "logs", "HEAD"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "(no branch)", nil
+		}
+		return "", err
+	}
+
+	lines := splitLines(b)
+	for i := len(lines) - 1; i >= 0; i-- {
+		meta, msg, _ := strings.Cut(lines[i], "\t")
+		_, to, found := strings.Cut(strings.TrimPrefix(msg, "checkout: moving from "), " to ")
+		if !found || !strings.HasPrefix(msg, "checkout: ") {
+			continue
+		}
+
+		f := strings.Fields(meta)
+		if len(f) < 2 {
+			continue
+		}
+		checkedOut := f[1]
+
+		from := ""
+		if ref, hash, ok, err := h.dwimRef(to); err == nil && ok {
+			if peeled, err := h.peel(hash); hash == checkedOut || (err == nil && peeled == checkedOut) {
+				from = strings.TrimPrefix(strings.TrimPrefix(ref, "refs/tags/"), "refs/remotes/")
+			}
+		}
+		if from == "" {
+			if from, err = h.abbrev(checkedOut, 0); err != nil {
+				return "", err
+			}
+		}
+
+		if checkedOut == head {
+			return "(HEAD detached at " + from + ")", nil
+		}
+		return "(HEAD detached from " + from + ")", nil
+	}
+
+	return "(no branch)", nil
+}
+
+// resolveCommit is like resolve, peeling tags to the commit they point to.
+func (h *nativeHandler) resolveCommit(rev string) (string, error) {
+	hash, err := h.resolve(rev)
+	if err != nil {
+		return "", err
+	}
+	return h.peel(hash)
+}
+
+func (h *nativeHandler) parent(hash string, n int, rev string) (string, error) {
+	c, err := h.commit(hash)
+	if err != nil {
+		return "", err
+	}
+	if n > len(c.Parents) {
+		return "", fmt.Errorf("revision %q does not exist", rev)
+	}
+	return c.Parents[n-1], nil
+}
+
+// walk visits the commits reachable from the given ones, by descending
+// committer date as `git log` does, until visit returns false.
+func (h *nativeHandler) walk(starts []string, visit func(c *nativeCommit) bool) error {
+	return h.walkExcluding(starts, nil, visit)
+}
+
+// walkExcluding is like walk, skipping the given commits and their ancestors.
+func (h *nativeHandler) walkExcluding(starts []string, excluded map[string]bool, visit func(c *nativeCommit) bool) error {
+	q := &commitQueue{}
+	seen := map[string]bool{}
+
+	push := func(hash string) error {
+		if seen[hash] || excluded[hash] {
+			return nil
+		}
+		seen[hash] = true
+
+		c, err := h.commit(hash)
+		if err != nil {
+			return err
+		}
+		q.seq++
+		heap.Push(q, commitQueueItem{commit: c, seq: q.seq})
+		return nil
+	}
+
+	for _, s := range starts {
+		if err := push(s); err != nil {
+			return err
+		}
+	}
+
+	for q.Len() > 0 {
+		c := heap.Pop(q).(commitQueueItem).commit
+		if !visit(c) {
+			return nil
+		}
+
+		for _, p := range c.Parents {
+			if err := push(p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+type commitQueueItem struct {
+	commit *nativeCommit
+	seq    int
+}
+
+// commitQueue sorts commits by descending committer date, then by
+// insertion order.
+type commitQueue struct {
+	items []commitQueueItem
+	seq   int
+}
+
+func (q *commitQueue) Len() int { return len(q.items) }
+
+func (q *commitQueue) Less(i, j int) bool {
+	a, b := q.items[i], q.items[j]
+	if a.commit.CommitTime != b.commit.CommitTime {
+		return a.commit.CommitTime > b.commit.CommitTime
+	}
+	return a.seq < b.seq
+}
+
+func (q *commitQueue) Swap(i, j int) { q.items[i], q.items[j] = q.items[j], q.items[i] }
+
+func (q *commitQueue) Push(x any) { q.items = append(q.items, x.(commitQueueItem)) }
+
+func (q *commitQueue) Pop() any {
+	last := q.items[len(q.items)-1]
+	q.items = q.items[:len(q.items)-1]
+	return last
+}
+
+// parseCommit parses a commit object.
+func parseCommit(hash string, data []byte) (*nativeCommit, error) {
+	header, message, _ := bytes.Cut(data, []byte("\n\n"))
+
+	c := &nativeCommit{Hash: hash, Message: string(message)}
+	for _, l := range strings.Split(string(header), "\n") {
+		// NOTE: continuation lines, e.g. of gpgsig, start with a space
+		key, value, found := strings.Cut(l, " ")
+		if !found || key == "" {
+			continue
+		}
+
+		switch key {
+		case "tree":
+			c.Tree = value
+		case "parent":
+			c.Parents = append(c.Parents, value)
+		case "author":
+			c.Author, c.Email, c.AuthorTime = parseIdentity(value)
+		case "committer":
+			_, _, c.CommitTime = parseIdentity(value)
+		}
+	}
+
+	if c.Tree == "" {
+		return nil, fmt.Errorf("invalid commit object %s", hash)
+	}
+	return c, nil
+}
+
+// parseIdentity parses `Name <email> timestamp timezone`.
+func parseIdentity(s string) (name, email string, ts int64) {
+	open := strings.Index(s, "<")
+	end := strings.LastIndex(s, ">")
+	if open < 0 || end < open {
+		return strings.TrimSpace(s), "", 0
+	}
+
+	name = strings.TrimSpace(s[:open])
+	email = s[open+1 : end]
+
+	if f := strings.Fields(s[end+1:]); len(f) > 0 {
+		ts, _ = strconv.ParseInt(f[0], 10, 64)
+	}
+	return
+}
+
+func parseTaggerTime(data []byte) int64 {
+	header, _, _ := bytes.Cut(data, []byte("\n\n"))
+	for _, l := range strings.Split(string(header), "\n") {
+		if v, ok := strings.CutPrefix(l, "tagger "); ok {
+			_, _, ts := parseIdentity(v)
+			return ts
+		}
+	}
+	return 0
+}
+
+// splitCommitMessage returns the subject and body of a commit message,
+// as formatted by `%s` and `%b`: the subject is the first paragraph,
+// joined in a single line, and the body is the rest.
+func splitCommitMessage(msg string) (subject, body string) {
+	lines := strings.SplitAfter(msg, "\n")
+
+	i := 0
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+
+	var parts []string
+	for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+		parts = append(parts, strings.TrimRight(lines[i], " \t\r\n"))
+	}
+	subject = strings.Join(parts, " ")
+
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	body = strings.TrimSuffix(strings.Join(lines[i:], ""), "\n")
+	return
+}
+
+// findTreeEntry looks for the given name in a tree object, made of
+// `mode SP name NUL hash` entries.
+func findTreeEntry(tree []byte, name string, hashLen int) (hash string, isTree, ok bool) {
+	for len(tree) > 0 {
+		sp := bytes.IndexByte(tree, ' ')
+		nul := bytes.IndexByte(tree, 0)
+		if sp < 0 || nul < sp || len(tree) < nul+1+hashLen {
+			return
+		}
+
+		mode := string(tree[:sp])
+		entry := string(tree[sp+1 : nul])
+		raw := tree[nul+1 : nul+1+hashLen]
+		tree = tree[nul+1+hashLen:]
+
+		if entry == name {
+			return hex.EncodeToString(raw), mode == "40000", true
+		}
+	}
+	return
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,192 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// maxSymrefDepth bounds the chain of symbolic references followed,
+// as git does.
+const maxSymrefDepth = 5
+
+// nativeRef defines a reference read from the repository.
+type nativeRef struct {
+	// Name is the full reference name, e.g. `refs/heads/main`.
+	Name string
+
+	// Hash is the object the reference points to, empty for symbolic
+	// references.
+	Hash string
+
+	// Target is the reference a symbolic one points to.
+	Target string
+}
+
+// refStore reads references from a repository, both loose and packed.
+// For linked worktrees, gitDir holds the worktree's own references,
+// e.g. HEAD, and commonDir the shared ones.
+type refStore struct {
+	gitDir    string
+	commonDir string
+}
+
+// read returns the reference with the given full name, which is
+// symbolic if its Target is set.
+func (s *refStore) read(name string) (ref nativeRef, ok bool, err error) {
+	for _, dir := range []string{s.gitDir, s.commonDir} {
+		b, rerr := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if rerr != nil {
+			if errors.Is(rerr, fs.ErrNotExist) || isDirErr(dir, name) {
+				continue
+			}
+			err = rerr
+			return
+		}
+
+		ref = parseLooseRef(name, b)
+		ok = true
+		return
+	}
+
+	if !strings.HasPrefix(name, "refs/") {
+		return
+	}
+
+	packed, err := s.packed()
+	if err != nil {
+		return
+	}
+	ref, ok = packed[name]
+	return
+}
+
+func isDirErr(dir, name string) bool {
+	fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+	return err == nil && fi.IsDir()
+}
+
+// resolve follows the given reference, if symbolic, and returns the
+// object it points to.
+func (s *refStore) resolve(name string) (hash string, ok bool, err error) {
+	for range maxSymrefDepth {
+		var ref nativeRef
+		if ref, ok, err = s.read(name); err != nil || !ok {
+			return
+		}
+
+		if ref.Target == "" {
+			hash = ref.Hash
+			return
+		}
+		name = ref.Target
+	}
+
+	err = fmt.Errorf("too many levels of symbolic references: %s", name)
+	return
+}
+
+// list returns the references whose names start with the given prefix,
+// e.g. `refs/tags/`, sorted by name.
+func (s *refStore) list(prefix string) ([]nativeRef, error) {
+	packed, err := s.packed()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := map[string]nativeRef{}
+	for name, ref := range packed {
+		if strings.HasPrefix(name, prefix) {
+			refs[name] = ref
+		}
+	}
+
+	dirs := []string{s.commonDir}
+	if s.gitDir != s.commonDir {
+		dirs = append(dirs, s.gitDir)
+	}
+
+	for _, dir := range dirs {
+		refsDir := filepath.Join(dir, "refs")
+		err := filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, werr error) error {
+			if werr != nil {
+				if errors.Is(werr, fs.ErrNotExist) {
+					return nil
+				}
+				return werr
+			}
+			if d.IsDir() || strings.HasSuffix(d.Name(), ".lock") {
+				return nil
+			}
+
+			rel, rerr := filepath.Rel(dir, path)
+			if rerr != nil {
+				return rerr
+			}
+
+			name := filepath.ToSlash(rel)
+			if !strings.HasPrefix(name, prefix) {
+				return nil
+			}
+
+			b, rerr := os.ReadFile(path)
+			if rerr != nil {
+				return rerr
+			}
+
+			refs[name] = parseLooseRef(name, b)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]nativeRef, 0, len(refs))
+	for _, ref := range refs {
+		list = append(list, ref)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return list, nil
+}
+
+// packed returns the references in the packed-refs file, by name.
+func (s *refStore) packed() (map[string]nativeRef, error) {
+	refs := map[string]nativeRef{}
+
+	b, err := os.ReadFile(filepath.Join(s.commonDir, "packed-refs"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return refs, nil
+		}
+		return nil, err
+	}
+
+	for _, l := range splitLines(b) {
+		// NOTE: `#` starts the header and `^` the peeled value of the previous tag
+		if l == "" || l[0] == '#' || l[0] == '^' {
+			continue
+		}
+
+		hash, name, found := strings.Cut(l, " ")
+		if !found {
+			return nil, fmt.Errorf("invalid packed-refs line: %q", l)
+		}
+		refs[name] = nativeRef{Name: name, Hash: hash}
+	}
+
+	return refs, nil
+}
+
+func parseLooseRef(name string, b []byte) nativeRef {
+	content := strings.TrimSpace(string(b))
+	if target, ok := strings.CutPrefix(content, "ref: "); ok {
+		return nativeRef{Name: name, Target: strings.TrimSpace(target)}
+	}
+	return nativeRef{Name: name, Hash: content}
+}
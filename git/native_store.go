@@ -0,0 +1,654 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"container/list"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jwmwalrus/bnp/onerror"
+)
+
+// objectType defines the type of a git object.
+type objectType string
+
+// Supported object types.
+const (
+	objectCommit objectType = "commit"
+	objectTree   objectType = "tree"
+	objectBlob   objectType = "blob"
+	objectTag    objectType = "tag"
+)
+
+// packObjectTypes maps the type codes used in packfiles to object types.
+// Codes 6 and 7 are deltas, resolved against a base object.
+var packObjectTypes = map[byte]objectType{
+	1: objectCommit,
+	2: objectTree,
+	3: objectBlob,
+	4: objectTag,
+}
+
+const (
+	packOfsDelta = 6
+	packRefDelta = 7
+)
+
+// errObjectNotFound is returned when an object is in none of the
+// object directories.
+var errObjectNotFound = errors.New("object not found")
+
+// objectStore reads objects from the object directories of a repository,
+// i.e. `objects` and its alternates, both loose and packed.
+type objectStore struct {
+	dirs    []string
+	hashLen int
+
+	mu    sync.Mutex
+	packs []*packFile
+
+	// files holds the packfiles whose handle is open, most recently
+	// used first
+	filesMu sync.Mutex
+	files   *list.List
+}
+
+// maxOpenPacks bounds the number of packfile handles kept open by an
+// objectStore, beyond those in use.
+const maxOpenPacks = 16
+
+func newObjectStore(objectsDir string, hashLen int) (*objectStore, error) {
+	s := &objectStore{dirs: []string{objectsDir}, hashLen: hashLen, files: list.New()}
+
+	// NOTE: alternates are listed one per line, relative to the objects directory
+	if b, err := os.ReadFile(filepath.Join(objectsDir, "info", "alternates")); err == nil {
+		for _, l := range splitLines(b) {
+			l = strings.TrimSpace(l)
+			if l == "" || strings.HasPrefix(l, "#") {
+				continue
+			}
+			if !filepath.IsAbs(l) {
+				l = filepath.Join(objectsDir, l)
+			}
+			s.dirs = append(s.dirs, l)
+		}
+	}
+
+	if err := s.loadPacks(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *objectStore) loadPacks() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	loaded := map[string]bool{}
+	for _, p := range s.packs {
+		loaded[p.path] = true
+	}
+
+	for _, dir := range s.dirs {
+		idxs, err := filepath.Glob(filepath.Join(dir, "pack", "*.idx"))
+		if err != nil {
+			return err
+		}
+
+		for _, idx := range idxs {
+			pack := strings.TrimSuffix(idx, ".idx") + ".pack"
+			if loaded[pack] {
+				continue
+			}
+
+			p, err := openPackFile(idx, pack, s.hashLen)
+			if err != nil {
+				return err
+			}
+			s.packs = append(s.packs, p)
+		}
+	}
+	return nil
+}
+
+// close closes the open packfile handles. Packs are reopened if read
+// again afterwards.
+func (s *objectStore) close() error {
+	s.filesMu.Lock()
+	defer s.filesMu.Unlock()
+
+	return s.closeFiles(0)
+}
+
+// openPack returns the handle of the given packfile, opening it if
+// needed. It must be released with releasePack.
+func (s *objectStore) openPack(p *packFile) (*os.File, error) {
+	s.filesMu.Lock()
+	defer s.filesMu.Unlock()
+
+	if p.file == nil {
+		f, err := os.Open(p.path)
+		if err != nil {
+			return nil, err
+		}
+		p.file = f
+		p.elem = s.files.PushFront(p)
+	} else {
+		s.files.MoveToFront(p.elem)
+	}
+	p.users++
+
+	return p.file, nil
+}
+
+// releasePack releases a handle returned by openPack, closing the least
+// recently used ones beyond maxOpenPacks.
+func (s *objectStore) releasePack(p *packFile) error {
+	s.filesMu.Lock()
+	defer s.filesMu.Unlock()
+
+	p.users--
+	return s.closeFiles(maxOpenPacks)
+}
+
+// closeFiles closes the least recently used packfile handles not in
+// use, until at most n are open.
+func (s *objectStore) closeFiles(n int) (err error) {
+	for e := s.files.Back(); e != nil && s.files.Len() > n; {
+		p := e.Value.(*packFile)
+		prev := e.Prev()
+		if p.users == 0 {
+			err = errors.Join(err, p.file.Close())
+			s.files.Remove(e)
+			p.file, p.elem = nil, nil
+		}
+		e = prev
+	}
+	return
+}
+
+// read returns the type and contents of the object with the given hash.
+func (s *objectStore) read(hash string) (typ objectType, data []byte, err error) {
+	typ, data, err = s.readOnce(hash)
+	if errors.Is(err, errObjectNotFound) {
+		// NOTE: the object may have been packed since packs were loaded
+		if lerr := s.loadPacks(); lerr != nil {
+			return "", nil, lerr
+		}
+		typ, data, err = s.readOnce(hash)
+	}
+	return
+}
+
+func (s *objectStore) readOnce(hash string) (objectType, []byte, error) {
+	if len(hash) != 2*s.hashLen {
+		return "", nil, fmt.Errorf("invalid object hash: %q", hash)
+	}
+
+	for _, dir := range s.dirs {
+		typ, data, err := readLooseObject(filepath.Join(dir, hash[:2], hash[2:]))
+		if err == nil {
+			return typ, data, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", nil, fmt.Errorf("reading object %s: %w", hash, err)
+		}
+	}
+
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid object hash: %q", hash)
+	}
+
+	s.mu.Lock()
+	packs := s.packs
+	s.mu.Unlock()
+
+	for _, p := range packs {
+		if off, ok := p.find(raw); ok {
+			typ, data, err := p.readAt(off, s)
+			if err != nil {
+				return "", nil, fmt.Errorf("reading object %s from %s: %w", hash, filepath.Base(p.path), err)
+			}
+
+			// NOTE: data may be cached, so callers get their own copy
+			return typ, bytes.Clone(data), nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("%w: %s", errObjectNotFound, hash)
+}
+
+// readTyped is like read, requiring the object to be of the given type.
+func (s *objectStore) readTyped(hash string, want objectType) ([]byte, error) {
+	typ, data, err := s.read(hash)
+	if err != nil {
+		return nil, err
+	}
+	if typ != want {
+		return nil, fmt.Errorf("object %s is a %s, not a %s", hash, typ, want)
+	}
+	return data, nil
+}
+
+// withPrefix returns the hashes of the objects starting with the given
+// hex prefix, up to limit of them, if greater than 0.
+func (s *objectStore) withPrefix(prefix string, limit int) ([]string, error) {
+	prefix = strings.ToLower(prefix)
+	found := map[string]bool{}
+	full := func() bool { return limit > 0 && len(found) >= limit }
+
+	if len(prefix) >= 2 {
+		for _, dir := range s.dirs {
+			entries, err := os.ReadDir(filepath.Join(dir, prefix[:2]))
+			if err != nil {
+				continue
+			}
+			for _, e := range entries {
+				hash := prefix[:2] + e.Name()
+				if len(hash) == 2*s.hashLen && strings.HasPrefix(hash, prefix) {
+					found[hash] = true
+					if full() {
+						return mapKeys(found), nil
+					}
+				}
+			}
+		}
+	}
+
+	if err := s.loadPacks(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	packs := s.packs
+	s.mu.Unlock()
+
+	for _, p := range packs {
+		for _, h := range p.withPrefix(prefix) {
+			found[h] = true
+			if full() {
+				return mapKeys(found), nil
+			}
+		}
+	}
+
+	return mapKeys(found), nil
+}
+
+func mapKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readLooseObject reads a zlib-compressed `type SP size NUL data` object.
+func readLooseObject(path string) (objectType, []byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil, err
+	}
+	defer f.Close()
+
+	zr, err := zlib.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	header, data, found := bytes.Cut(b, []byte{0})
+	if !found {
+		return "", nil, errors.New("invalid loose object header")
+	}
+
+	typ, size, found := strings.Cut(string(header), " ")
+	if !found {
+		return "", nil, errors.New("invalid loose object header")
+	}
+
+	if n, err := strconv.Atoi(size); err != nil || n != len(data) {
+		return "", nil, errors.New("loose object size mismatch")
+	}
+
+	return objectType(typ), data, nil
+}
+
+// packFile reads objects from a packfile, located through its version 2 index.
+type packFile struct {
+	path    string
+	hashLen int
+
+	fanout  [256]uint32
+	names   []byte
+	offsets []byte
+	large   []byte
+
+	// file, elem and users are guarded by the objectStore's filesMu
+	file  *os.File
+	elem  *list.Element
+	users int
+
+	// cache holds the last objects read, most recently used first in lru
+	mu    sync.Mutex
+	cache map[int64]*list.Element
+	lru   *list.List
+}
+
+type packObject struct {
+	off  int64
+	typ  objectType
+	data []byte
+}
+
+// packCacheSize bounds the number of objects cached per packfile,
+// mostly to speed up the resolution of delta chains. The least recently
+// used one is evicted first.
+const packCacheSize = 512
+
+func openPackFile(idxPath, packPath string, hashLen int) (*packFile, error) {
+	idx, err := os.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) ||
+		binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("unsupported pack index: %s", idxPath)
+	}
+
+	p := &packFile{path: packPath, hashLen: hashLen, cache: map[int64]*list.Element{}, lru: list.New()}
+	for i := range p.fanout {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+4*i:])
+	}
+
+	n := int(p.fanout[255])
+	pos := 8 + 256*4
+	if len(idx) < pos+n*(hashLen+4+4) {
+		return nil, fmt.Errorf("truncated pack index: %s", idxPath)
+	}
+
+	p.names = idx[pos : pos+n*hashLen]
+	pos += n * hashLen
+
+	// NOTE: CRC32 values are skipped
+	pos += n * 4
+
+	p.offsets = idx[pos : pos+n*4]
+	pos += n * 4
+
+	p.large = idx[pos:]
+
+	// NOTE: the pack is opened on demand, and kept open by the objectStore
+	if _, err = os.Stat(packPath); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *packFile) name(i int) []byte {
+	return p.names[i*p.hashLen : (i+1)*p.hashLen]
+}
+
+func (p *packFile) offset(i int) int64 {
+	off := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if off&0x80000000 == 0 {
+		return int64(off)
+	}
+
+	j := int(off & 0x7fffffff)
+	return int64(binary.BigEndian.Uint64(p.large[j*8:]))
+}
+
+// bounds returns the range of index entries whose first byte is b.
+func (p *packFile) bounds(b byte) (lo, hi int) {
+	if b > 0 {
+		lo = int(p.fanout[b-1])
+	}
+	hi = int(p.fanout[b])
+	return
+}
+
+func (p *packFile) find(hash []byte) (int64, bool) {
+	lo, hi := p.bounds(hash[0])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.name(lo+i), hash) >= 0
+	})
+	if i < hi && bytes.Equal(p.name(i), hash) {
+		return p.offset(i), true
+	}
+	return 0, false
+}
+
+func (p *packFile) withPrefix(prefix string) (list []string) {
+	if len(prefix) < 2 {
+		return
+	}
+
+	first, err := hex.DecodeString(prefix[:2])
+	if err != nil {
+		return
+	}
+
+	lo, hi := p.bounds(first[0])
+	for i := lo; i < hi; i++ {
+		h := hex.EncodeToString(p.name(i))
+		if strings.HasPrefix(h, prefix) {
+			list = append(list, h)
+		}
+	}
+	return
+}
+
+// readAt reads the object at the given offset, resolving deltas.
+func (p *packFile) readAt(off int64, s *objectStore) (objectType, []byte, error) {
+	p.mu.Lock()
+	if e, ok := p.cache[off]; ok {
+		p.lru.MoveToFront(e)
+		o := e.Value.(packObject)
+		p.mu.Unlock()
+		return o.typ, o.data, nil
+	}
+	p.mu.Unlock()
+
+	f, err := s.openPack(p)
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() { onerror.Log(s.releasePack(p)) }()
+
+	r := bufio.NewReader(io.NewSectionReader(f, off, 1<<62))
+
+	c, err := r.ReadByte()
+	if err != nil {
+		return "", nil, err
+	}
+
+	code := (c >> 4) & 7
+	size := int64(c & 0x0f)
+	for shift := 4; c&0x80 != 0; shift += 7 {
+		if c, err = r.ReadByte(); err != nil {
+			return "", nil, err
+		}
+		size |= int64(c&0x7f) << shift
+	}
+
+	var baseType objectType
+	var base []byte
+
+	switch code {
+	case packOfsDelta:
+		if c, err = r.ReadByte(); err != nil {
+			return "", nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return "", nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+
+		if baseType, base, err = p.readAt(off-rel, s); err != nil {
+			return "", nil, err
+		}
+
+	case packRefDelta:
+		ref := make([]byte, p.hashLen)
+		if _, err = io.ReadFull(r, ref); err != nil {
+			return "", nil, err
+		}
+
+		if baseType, base, err = s.read(hex.EncodeToString(ref)); err != nil {
+			return "", nil, err
+		}
+
+	default:
+		if _, ok := packObjectTypes[code]; !ok {
+			return "", nil, fmt.Errorf("unsupported pack object type %d at offset %d", code, off)
+		}
+	}
+
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return "", nil, err
+	}
+	defer zr.Close()
+
+	data := make([]byte, size)
+	if _, err = io.ReadFull(zr, data); err != nil {
+		return "", nil, err
+	}
+
+	typ := packObjectTypes[code]
+	if base != nil {
+		typ = baseType
+		if data, err = applyDelta(base, data); err != nil {
+			return "", nil, fmt.Errorf("delta at offset %d: %w", off, err)
+		}
+	}
+
+	p.remember(packObject{off: off, typ: typ, data: data})
+
+	return typ, data, nil
+}
+
+// remember caches the given object, evicting the least recently used one
+// beyond packCacheSize.
+func (p *packFile) remember(o packObject) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.cache[o.off]; ok {
+		return
+	}
+
+	p.cache[o.off] = p.lru.PushFront(o)
+	if p.lru.Len() > packCacheSize {
+		e := p.lru.Back()
+		delete(p.cache, e.Value.(packObject).off)
+		p.lru.Remove(e)
+	}
+}
+
+// applyDelta applies a packfile delta to the given base object.
+func applyDelta(base, delta []byte) ([]byte, error) {
+	varint := func() (n int, err error) {
+		for shift := 0; ; shift += 7 {
+			if len(delta) == 0 {
+				return 0, errors.New("truncated delta header")
+			}
+			c := delta[0]
+			delta = delta[1:]
+			n |= int(c&0x7f) << shift
+			if c&0x80 == 0 {
+				return
+			}
+		}
+	}
+
+	srcSize, err := varint()
+	if err != nil {
+		return nil, err
+	}
+	if srcSize != len(base) {
+		return nil, errors.New("delta base size mismatch")
+	}
+
+	dstSize, err := varint()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+
+		switch {
+		case op&0x80 != 0:
+			// copy from base: offset and size bytes are present per bit
+			var off, n int
+			for i := range 4 {
+				if op&(1<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta copy")
+					}
+					off |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			for i := range 3 {
+				if op&(0x10<<i) != 0 {
+					if len(delta) == 0 {
+						return nil, errors.New("truncated delta copy")
+					}
+					n |= int(delta[0]) << (8 * i)
+					delta = delta[1:]
+				}
+			}
+			if n == 0 {
+				n = 0x10000
+			}
+			if off+n > len(base) {
+				return nil, errors.New("delta copy out of bounds")
+			}
+			out = append(out, base[off:off+n]...)
+
+		case op != 0:
+			// insert the next op bytes
+			n := int(op)
+			if n > len(delta) {
+				return nil, errors.New("truncated delta insert")
+			}
+			out = append(out, delta[:n]...)
+			delta = delta[n:]
+
+		default:
+			return nil, errors.New("invalid delta opcode")
+		}
+	}
+
+	if len(out) != dstSize {
+		return nil, errors.New("delta result size mismatch")
+	}
+	return out, nil
+}
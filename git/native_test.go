@@ -0,0 +1,280 @@
+package git
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jwmwalrus/bnp/tests"
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestObjectStorePacks(t *testing.T) {
+	dir := tests.NewTempDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newObjectStore(dir, 20)
+	assert.NoError(t, err)
+
+	var packs []*packFile
+	for i := range maxOpenPacks + 2 {
+		path := filepath.Join(dir, fmt.Sprintf("pack-%d.pack", i))
+		err = os.WriteFile(path, []byte("PACK"), 0644)
+		assert.NoError(t, err)
+		packs = append(packs, &packFile{path: path})
+	}
+
+	_, err = s.openPack(packs[0])
+	assert.NoError(t, err)
+
+	for _, p := range packs[1:] {
+		_, err = s.openPack(p)
+		assert.NoError(t, err)
+		err = s.releasePack(p)
+		assert.NoError(t, err)
+	}
+
+	// NOTE: the first pack is still in use, so it is not closed
+	assert.Equal(t, maxOpenPacks, s.files.Len())
+	assert.Equal(t, true, packs[0].file != nil)
+	assert.Equal(t, true, packs[1].file == nil)
+	assert.Equal(t, true, packs[2].file == nil)
+
+	err = s.releasePack(packs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, maxOpenPacks, s.files.Len())
+
+	err = s.close()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, s.files.Len())
+
+	p := &packFile{cache: map[int64]*list.Element{}, lru: list.New()}
+	for off := range int64(packCacheSize + 1) {
+		p.remember(packObject{off: off, typ: objectBlob})
+		if off == 1 {
+			_, _, err = p.readAt(0, s)
+			assert.NoError(t, err)
+		}
+	}
+
+	// NOTE: the object at 0 was used recently, so the one at 1 is evicted
+	assert.Equal(t, packCacheSize, len(p.cache))
+	_, ok := p.cache[0]
+	assert.Equal(t, true, ok)
+	_, ok = p.cache[1]
+	assert.Equal(t, false, ok)
+}
+
+func TestNativeHandler(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	exec := g.(*handlerImpl)
+	date := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	commit := func(msg string, files map[string]string) {
+		var names []string
+		for name, content := range files {
+			path := filepath.Join(dir, name)
+			err := os.MkdirAll(filepath.Dir(path), 0755)
+			assert.NoError(t, err)
+			err = os.WriteFile(path, []byte(content), 0644)
+			assert.NoError(t, err)
+			names = append(names, path)
+		}
+
+		date = date.Add(time.Hour)
+		_, err := g.CommitWithOptions(CommitOptions{Message: msg, Files: names, Date: date})
+		assert.NoError(t, err)
+	}
+
+	// NOTE: a large file changing slightly, so that packing creates deltas
+	var lines []string
+	for i := range 300 {
+		lines = append(lines, fmt.Sprintf("line %d of the tracked file", i))
+	}
+	large := func(i int) string {
+		changed := append([]string{}, lines...)
+		changed[i*10] = "changed"
+		return strings.Join(changed, "\n") + "\n"
+	}
+
+	commit("Initial commit", map[string]string{"large.txt": large(0), "docs/readme.md": "readme\n"})
+	commit("Multi-line\nsubject\n\nFirst paragraph.\n\nSecond paragraph.\n", map[string]string{"large.txt": large(1)})
+
+	err := g.NewTag("v1.0", "Release 1.0")
+	assert.NoError(t, err)
+
+	commit("Third commit", map[string]string{"large.txt": large(2)})
+
+	err = g.CheckoutNewBranch("feature")
+	assert.NoError(t, err)
+	commit("Feature commit", map[string]string{"feature.txt": "feature\n"})
+
+	err = g.CheckoutBranch("main")
+	assert.NoError(t, err)
+	commit("Main commit", map[string]string{"large.txt": large(3)})
+
+	_, err = exec.executeEnv(
+		[]string{"GIT_COMMITTER_DATE=" + date.Add(time.Hour).Format(time.RFC3339)},
+		"merge", "--no-ff", "--message", "Merge feature", "feature",
+	)
+	assert.NoError(t, err)
+
+	_, err = exec.execute("tag", "light")
+	assert.NoError(t, err)
+
+	clone := tests.NewTempDir(t)
+	defer os.RemoveAll(clone)
+
+	cg, err := NewHandler(clone)
+	assert.NoError(t, err)
+	err = cg.Clone(dir, CloneOptions{})
+	assert.NoError(t, err)
+
+	compare := func(t *testing.T, repo string) {
+		eg, err := NewHandler(repo)
+		assert.NoError(t, err)
+
+		ng, err := NewNativeHandler(repo)
+		assert.NoError(t, err)
+		defer ng.Close()
+
+		assert.Equal(t, eg.TopLevel(), ng.TopLevel())
+
+		check := func(name string, get func(Handler) (any, error)) {
+			want, werr := get(eg)
+			got, gerr := get(ng)
+			if (werr != nil) != (gerr != nil) {
+				t.Errorf("%s: exec error %v, native error %v", name, werr, gerr)
+				return
+			}
+			if fmt.Sprint(want) != fmt.Sprint(got) {
+				t.Errorf("%s: exec %v, native %v", name, want, got)
+			}
+		}
+
+		check("Branch", func(h Handler) (any, error) { return h.Branch() })
+		check("Branches", func(h Handler) (any, error) { return h.Branches() })
+		check("Branches all", func(h Handler) (any, error) { return h.Branches(true) })
+		check("LatestHash", func(h Handler) (any, error) { return h.LatestHash() })
+		check("LatestTag", func(h Handler) (any, error) { return h.LatestTag(true) })
+		check("Log", func(h Handler) (any, error) { return h.Log(0) })
+		check("Log 2", func(h Handler) (any, error) { return h.Log(2) })
+		check("Describe", func(h Handler) (any, error) { return h.Describe("") })
+		check("Describe tag", func(h Handler) (any, error) { return h.Describe("v1.0") })
+		check("SymbolicRef", func(h Handler) (any, error) { return h.SymbolicRef("HEAD") })
+		check("ShortHash", func(h Handler) (any, error) { return h.ShortHash("HEAD") })
+		check("ShortHash 12", func(h Handler) (any, error) { return h.ShortHash("v1.0", 12) })
+		check("IsAncestor", func(h Handler) (any, error) { return h.IsAncestor("v1.0", "HEAD") })
+		check("IsAncestor not", func(h Handler) (any, error) { return h.IsAncestor("HEAD", "v1.0") })
+		check("RevListCount", func(h Handler) (any, error) { return h.RevListCount("v1.0", "HEAD") })
+		check("RevListCount all", func(h Handler) (any, error) { return h.RevListCount("", "HEAD") })
+
+		for _, rev := range []string{"HEAD", "HEAD~1", "HEAD^2", "HEAD~2^{commit}", "v1.0", "light", "feature", "missing"} {
+			check("ResolveRev "+rev, func(h Handler) (any, error) { return h.ResolveRev(rev) })
+		}
+
+		short, err := eg.ShortHash("HEAD~1")
+		assert.NoError(t, err)
+		check("ResolveRev short", func(h Handler) (any, error) { return h.ResolveRev(short) })
+
+		for _, f := range []string{"large.txt", "docs/readme.md", "feature.txt", "docs", "missing.txt"} {
+			check("FileAt "+f, func(h Handler) (any, error) {
+				b, err := h.FileAt("HEAD", f)
+				return string(b), err
+			})
+		}
+		check("FileAt v1.0", func(h Handler) (any, error) {
+			b, err := h.FileAt("v1.0", "large.txt")
+			return string(b), err
+		})
+	}
+
+	t.Run("Loose", func(t *testing.T) {
+		compare(t, dir)
+		compare(t, clone)
+	})
+
+	t.Run("Packed", func(t *testing.T) {
+		err := g.GC(GCOptions{Aggressive: true, Prune: "now"})
+		assert.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(dir, ".git", "packed-refs"))
+		assert.NoError(t, err)
+
+		packs, err := filepath.Glob(filepath.Join(dir, ".git", "objects", "pack", "*.idx"))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(packs))
+
+		out, err := exec.execute("verify-pack", "--verbose", packs[0])
+		assert.NoError(t, err)
+		assert.Equal(t, true, strings.Contains(string(out), "chain length = 1"))
+
+		compare(t, dir)
+
+		ng, err := NewNativeHandler(dir)
+		assert.NoError(t, err)
+
+		b, err := ng.FileAt("HEAD", "large.txt")
+		assert.NoError(t, err)
+		want := string(b)
+		clear(b)
+
+		b, err = ng.FileAt("HEAD", "large.txt")
+		assert.NoError(t, err)
+		assert.Equal(t, want, string(b))
+
+		// NOTE: packs are kept open between reads, until the handler is closed
+		openPacks := func() (n int) {
+			fds, err := os.ReadDir("/proc/self/fd")
+			if err != nil {
+				t.Skip("no /proc/self/fd")
+			}
+			for _, fd := range fds {
+				target, _ := os.Readlink(filepath.Join("/proc/self/fd", fd.Name()))
+				if strings.HasSuffix(target, ".pack") {
+					n++
+				}
+			}
+			return
+		}
+		assert.Equal(t, 1, openPacks())
+
+		err = ng.Close()
+		assert.NoError(t, err)
+		assert.Equal(t, 0, openPacks())
+	})
+
+	t.Run("Detached", func(t *testing.T) {
+		err := g.CheckoutBranch("v1.0")
+		assert.NoError(t, err)
+		defer g.CheckoutBranch("main")
+
+		compare(t, dir)
+
+		commit("Detached commit", map[string]string{"detached.txt": "detached\n"})
+		compare(t, dir)
+	})
+
+	t.Run("Fallback", func(t *testing.T) {
+		t.Setenv("PATH", "")
+
+		fg, err := NewHandler(filepath.Join(dir, "docs"))
+		assert.NoError(t, err)
+		assert.Equal(t, dir, fg.TopLevel())
+
+		_, ok := fg.(*nativeHandler)
+		assert.Equal(t, true, ok)
+
+		err = fg.Commit("Not supported")
+		assert.Equal(t, true, errors.Is(err, ErrNotSupported))
+
+		_, err = NewHandler(os.TempDir())
+		assert.Error(t, err)
+	})
+}
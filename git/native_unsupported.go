@@ -0,0 +1,449 @@
+package git
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// The operations below require the git command, since they modify the
+// repository, the working tree or the index, or rely on features beyond
+// reading objects and references, e.g. configuration or networking.
+
+func (h *nativeHandler) AbortMailbox() error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) AddConfig(scope ConfigScope, key, value string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) AddNote(notesRef, rev, msg string, force ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) AddToStaging(files []string) (err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) AheadBehind(rev, upstream string) (ahead, behind int, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) ApplyMailbox(files []string, threeWay ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) ApplyPatch(file string, opts ApplyOptions) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) ApplyStash(index int, restoreIndex ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Archive(w io.Writer, opts ArchiveOptions) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) ArchiveLatestTag(w io.Writer, opts ArchiveOptions) (tag string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) Bisect(ctx context.Context, opts BisectOptions, test BisectFunc) (result BisectResult, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) BisectLog() (string, error) {
+	return "", ErrNotSupported
+}
+
+func (h *nativeHandler) BisectMark(term BisectTerm, revs ...string) (step BisectStep, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) BisectReset() error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) BisectRun(ctx context.Context, opts BisectOptions, name string, args ...string) (BisectResult, error) {
+	return BisectResult{}, ErrNotSupported
+}
+
+func (h *nativeHandler) BisectStart(opts BisectOptions) (step BisectStep, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) CheckAttr(paths []string, attrs ...string) ([]PathAttributes, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) CheckIgnore(paths []string) ([]IgnoreResult, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) CheckPolicy(policy *Policy) (report PolicyReport, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) CheckoutBranch(name string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) CheckoutNewBranch(name string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Clean(opts CleanOptions) (paths []string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) Clone(url string, opts CloneOptions) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Commit(msg string) (err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) CommitFiles(files []string, msg string) (err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) CommitWithOptions(opts CommitOptions) (hash string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) Config(key string) (value string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) ConfigAll(scope ConfigScope, key string) (values []string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) ConfigAt(scope ConfigScope, key string) (value string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) ConfigBool(scope ConfigScope, key string) (bool, error) {
+	return false, ErrNotSupported
+}
+
+func (h *nativeHandler) ConfigExpiryDate(scope ConfigScope, key string) (time.Time, error) {
+	return time.Time{}, ErrNotSupported
+}
+
+func (h *nativeHandler) ConfigInt(scope ConfigScope, key string) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func (h *nativeHandler) ConfigList(scope ConfigScope, pattern string) (list []ConfigEntry, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) ConfigPath(scope ConfigScope, key string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (h *nativeHandler) ConfigWithOrigin(scope ConfigScope, key string) (entry ConfigEntry, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) ContinueMailbox() error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Contributors(rng string) (report ContributorReport, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) CountObjects() (ObjectCounts, error) {
+	return ObjectCounts{}, ErrNotSupported
+}
+
+func (h *nativeHandler) CreateBundle(file string, revs ...string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) DeleteBranch(name string, force ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) DiffUpstream(remote, branch string) (differs bool, diff string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) DropStash(all ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) DropStashAt(index int) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Fetch(remote string) (err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) FetchNotes(remote, notesRef string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) FileChanged(file string) bool {
+	return false
+}
+
+func (h *nativeHandler) FileHistory(path string, maxCount int) (list []FileHistoryEntry, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) FormatPatch(rng, outputDir string) (files []string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) FormatPatchTo(w io.Writer, rng string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Fsck(unreachable ...bool) ([]FsckFinding, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) GC(opts GCOptions) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Health() (HealthReport, error) {
+	return HealthReport{}, ErrNotSupported
+}
+
+func (h *nativeHandler) Init(initialBranch string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) InitWithOptions(opts InitOptions) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) ListBundleHeads(file string) ([]BundleHead, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) ListNotes(notesRef string) ([]NoteEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) LogWithNotes(maxCount int, notesRef string) ([]LogEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) LostCommits() ([]LogEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) MaterializedPaths() ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) MergeBase(revs ...string) (hash string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) MergeStash(remote, branch, commitMsg string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) NewBranch(name string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) NewTag(tag, msg string) (err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) PopStash(msg string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) PopStashAt(index int) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Prune(expire string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Pull(remote, branch string, noCommit ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Push(remote, branch string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) PushNotes(remote, notesRef string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) RecoverCommit(hash, branch string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Reflog(ref string) ([]ReflogEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) Remotes() (list map[string]string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) RemoteUpdate() error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) RemoveFromStaging(files []string, ignoreErrors ...bool) (err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) RemoveNote(notesRef, rev string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Repack(all ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Reset(mode ResetMode, rev string) (paths []string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) Restore(opts RestoreOptions) (paths []string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) Revert(commits []string, noCommit ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) SetUpstreamBranchTo(remote, branch string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Scaffold(opts ScaffoldOptions) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) SetConfig(key, value string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) SetConfigAt(scope ConfigScope, key, value string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) SetConfigFile(scope ConfigScope, file string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) SetRemote(name, url string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) ShowNote(notesRef, rev string) (note string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) ShowStash(index int) (files []string, diff string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) SparseCheckoutAdd(dirs ...string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) SparseCheckoutDisable() error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) SparseCheckoutInit(cone bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) SparseCheckoutList() ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) SparseCheckoutSet(dirs ...string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Stash(msg string, untracked ...bool) (StashEntry, error) {
+	return StashEntry{}, ErrNotSupported
+}
+
+func (h *nativeHandler) StashBranch(name string, index int) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) StashList() ([]StashEntry, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) StashWithOptions(opts StashOptions) (StashEntry, error) {
+	return StashEntry{}, ErrNotSupported
+}
+
+func (h *nativeHandler) Status() (staged, unstaged, untracked []string, err error) {
+	err = ErrNotSupported
+	return
+}
+
+func (h *nativeHandler) UnsetConfig(scope ConfigScope, key string, all ...bool) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Unstage(files []string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) VerifyBundle(file string) error {
+	return ErrNotSupported
+}
+
+func (h *nativeHandler) Watch(ctx context.Context, opts WatchOptions) (<-chan WatchEvent, error) {
+	return nil, ErrNotSupported
+}
+
+func (h *nativeHandler) WriteCommitGraph() error {
+	return ErrNotSupported
+}
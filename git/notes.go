@@ -0,0 +1,201 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DefaultNotesRef is the notes reference used when none is given.
+const DefaultNotesRef = "refs/notes/commits"
+
+// ErrNoteNotFound is returned when the given object has no note.
+var ErrNoteNotFound = errors.New("no note found")
+
+// NoteEntry defines a note attached to an object.
+type NoteEntry struct {
+	// Object is the hash of the annotated object.
+	Object string `json:"object"`
+
+	Note string `json:"note"`
+}
+
+func (h *handlerImpl) AddNote(notesRef, rev, msg string, force ...bool) error {
+	h.log.With(
+		"notes-ref", notesRef,
+		"rev", rev,
+		"msg", msg,
+		"force", force,
+	).Info("Adding note")
+
+	args := []string{"notes", "--ref", fullNotesRef(notesRef), "add", "--message", msg}
+	if len(force) > 0 && force[0] {
+		args = append(args, "--force")
+	}
+	args = append(args, rev)
+
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) FetchNotes(remote, notesRef string) error {
+	h.log.With(
+		"remote", remote,
+		"notes-ref", notesRef,
+	).Info("Fetching notes")
+
+	ref := fullNotesRef(notesRef)
+	return h.executeNO("fetch", remote, ref+":"+ref)
+}
+
+func (h *handlerImpl) ListNotes(notesRef string) ([]NoteEntry, error) {
+	h.log.Info("Listing notes", "notes-ref", notesRef)
+
+	out, err := h.execute("notes", "--ref", fullNotesRef(notesRef), "list")
+	if err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(out)
+	if len(lines) == 0 {
+		return []NoteEntry{}, nil
+	}
+
+	list := make([]NoteEntry, 0, len(lines))
+	blobs := &strings.Builder{}
+	for _, l := range lines {
+		blob, object, found := strings.Cut(l, " ")
+		if !found {
+			return nil, fmt.Errorf("unexpected notes list line: %q", l)
+		}
+		list = append(list, NoteEntry{Object: object})
+		blobs.WriteString(blob + "\n")
+	}
+
+	out, err = h.executeStdin(strings.NewReader(blobs.String()), "cat-file", "--batch")
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err := parseCatFileBatch(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(contents) != len(list) {
+		return nil, fmt.Errorf("expected %d notes but got %d", len(list), len(contents))
+	}
+
+	for i := range list {
+		list[i].Note = strings.TrimSuffix(string(contents[i]), "\n")
+	}
+
+	return list, nil
+}
+
+func (h *handlerImpl) LogWithNotes(maxCount int, notesRef string) ([]LogEntry, error) {
+	h.log.With(
+		"max-count", maxCount,
+		"notes-ref", notesRef,
+	).Info("Getting log with notes")
+
+	args := []string{"log", "--notes=" + fullNotesRef(notesRef)}
+
+	if maxCount > 0 {
+		args = append(args, "--max-count", strconv.Itoa(maxCount))
+	}
+
+	return h.logEntriesWithFormat(logEntryNotesFormat, args...)
+}
+
+func (h *handlerImpl) PushNotes(remote, notesRef string) error {
+	h.log.With(
+		"remote", remote,
+		"notes-ref", notesRef,
+	).Info("Pushing notes")
+
+	ref := fullNotesRef(notesRef)
+	return h.executeNO("push", remote, ref+":"+ref)
+}
+
+func (h *handlerImpl) RemoveNote(notesRef, rev string) error {
+	h.log.With(
+		"notes-ref", notesRef,
+		"rev", rev,
+	).Info("Removing note")
+
+	return h.noteNotFound(h.executeNO("notes", "--ref", fullNotesRef(notesRef), "remove", rev))
+}
+
+func (h *handlerImpl) ShowNote(notesRef, rev string) (note string, err error) {
+	h.log.With(
+		"notes-ref", notesRef,
+		"rev", rev,
+	).Info("Showing note")
+
+	out, err := h.execute("notes", "--ref", fullNotesRef(notesRef), "show", rev)
+	if err = h.noteNotFound(err); err != nil {
+		return
+	}
+
+	note = strings.TrimSuffix(string(out), "\n")
+	return
+}
+
+// noteNotFound translates git's failure for missing notes into ErrNoteNotFound.
+func (h *handlerImpl) noteNotFound(err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 &&
+		(strings.Contains(err.Error(), "no note found") || strings.Contains(err.Error(), "has no note")) {
+		return fmt.Errorf("%w: %v", ErrNoteNotFound, err)
+	}
+	return err
+}
+
+// fullNotesRef returns the fully qualified notes reference for the given one.
+func fullNotesRef(ref string) string {
+	if ref == "" {
+		return DefaultNotesRef
+	}
+	if strings.HasPrefix(ref, "refs/") {
+		return ref
+	}
+	return "refs/notes/" + ref
+}
+
+// parseCatFileBatch parses the output of `git cat-file --batch`,
+// returning the contents of each object.
+func parseCatFileBatch(out []byte) (contents [][]byte, err error) {
+	r := bufio.NewReader(bytes.NewReader(out))
+	for {
+		var header string
+		header, err = r.ReadString('\n')
+		if err == io.EOF && header == "" {
+			err = nil
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(header)
+		if len(fields) < 3 {
+			err = fmt.Errorf("unexpected cat-file header: %q", header)
+			return
+		}
+
+		var size int
+		if size, err = strconv.Atoi(fields[2]); err != nil {
+			return
+		}
+
+		b := make([]byte, size+1)
+		if _, err = io.ReadFull(r, b); err != nil {
+			return
+		}
+		contents = append(contents, b[:size])
+	}
+}
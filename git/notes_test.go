@@ -0,0 +1,99 @@
+package git
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests"
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestNotes(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := "file.txt"
+	err := os.WriteFile(filepath.Join(dir, file), []byte{}, 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	hash, err := g.LatestHash(true)
+	assert.NoError(t, err)
+
+	_, err = g.ShowNote("ci", "HEAD")
+	assert.Equal(t, true, errors.Is(err, ErrNoteNotFound))
+
+	err = g.AddNote("ci", "HEAD", "build: passed\nduration: 42s")
+	assert.NoError(t, err)
+
+	err = g.AddNote("", "HEAD", "default note")
+	assert.NoError(t, err)
+
+	err = g.AddNote("ci", "HEAD", "build: failed")
+	assert.Error(t, err)
+
+	note, err := g.ShowNote("refs/notes/ci", "HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, "build: passed\nduration: 42s", note)
+
+	list, err := g.ListNotes("ci")
+	assert.NoError(t, err)
+	assert.Equal(t, []NoteEntry{{Object: hash, Note: "build: passed\nduration: 42s"}}, list)
+
+	entries, err := g.LogWithNotes(0, "ci")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "build: passed\nduration: 42s", entries[0].Notes)
+
+	entries, err = g.Log(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "", entries[0].Notes)
+
+	// remote round trip
+
+	remote := tests.NewTempDir(t)
+	defer os.RemoveAll(remote)
+
+	_, err = exec.Command("git", "init", "--bare", remote).CombinedOutput()
+	assert.NoError(t, err)
+
+	err = g.SetRemote("origin", remote)
+	assert.NoError(t, err)
+
+	err = g.Push("origin", "main")
+	assert.NoError(t, err)
+
+	err = g.PushNotes("origin", "ci")
+	assert.NoError(t, err)
+
+	g2, dir2 := newTestRepo(t, "")
+	defer os.RemoveAll(dir2)
+
+	err = g2.SetRemote("origin", remote)
+	assert.NoError(t, err)
+
+	err = g2.Pull("origin", "main")
+	assert.NoError(t, err)
+
+	err = g2.FetchNotes("origin", "ci")
+	assert.NoError(t, err)
+
+	note, err = g2.ShowNote("ci", hash)
+	assert.NoError(t, err)
+	assert.Equal(t, "build: passed\nduration: 42s", note)
+
+	err = g2.RemoveNote("ci", hash)
+	assert.NoError(t, err)
+
+	err = g2.RemoveNote("ci", hash)
+	assert.Equal(t, true, errors.Is(err, ErrNoteNotFound))
+
+	list, err = g2.ListNotes("ci")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(list))
+}
@@ -0,0 +1,259 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"regexp"
+	"strings"
+)
+
+// ApplyOptions defines the options supported by ApplyPatch.
+type ApplyOptions struct {
+	// Check only verifies that the patch applies, without changing anything.
+	Check bool `json:"check,omitempty"`
+
+	// ThreeWay falls back to a three-way merge when the patch does not apply cleanly.
+	ThreeWay bool `json:"threeWay,omitempty"`
+
+	// Index applies the patch to both the index and the working tree.
+	Index bool `json:"index,omitempty"`
+}
+
+// PatchEntry defines a patch in mbox format, as produced by FormatPatch.
+type PatchEntry struct {
+	LogEntry
+
+	// Diff is the patch's diff, without the commit message nor the diffstat.
+	Diff string `json:"diff"`
+}
+
+// PatchConflictError is returned when a patch being applied by
+// ApplyMailbox does not apply cleanly.
+type PatchConflictError struct {
+	// Subject is the subject of the failing patch.
+	Subject string
+
+	// Files are the unmerged files, if any.
+	Files []string
+
+	Err error
+}
+
+func (e *PatchConflictError) Error() string {
+	if len(e.Files) == 0 {
+		return fmt.Sprintf("patch %q does not apply: %v", e.Subject, e.Err)
+	}
+	return fmt.Sprintf("patch %q conflicts in %s: %v", e.Subject, strings.Join(e.Files, ", "), e.Err)
+}
+
+func (e *PatchConflictError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	mboxFromLine  = regexp.MustCompile(`^From ([0-9a-f]{40,64}) `)
+	patchSubjTags = regexp.MustCompile(`^\[[^]]*PATCH[^]]*\]\s*`)
+)
+
+func (h *handlerImpl) AbortMailbox() error {
+	h.log.Info("Aborting mailbox application")
+
+	return h.executeNO("am", "--abort")
+}
+
+func (h *handlerImpl) ApplyMailbox(files []string, threeWay ...bool) error {
+	h.log.With(
+		"files", files,
+		"three-way", threeWay,
+	).Info("Applying mailbox")
+
+	args := []string{"am"}
+	if len(threeWay) > 0 && threeWay[0] {
+		args = append(args, "--3way")
+	}
+	args = append(args, "--")
+	args = append(args, files...)
+
+	return h.mailboxConflict(h.executeNO(args...))
+}
+
+func (h *handlerImpl) ApplyPatch(file string, opts ApplyOptions) error {
+	h.log.With(
+		"file", file,
+		"check", opts.Check,
+		"three-way", opts.ThreeWay,
+		"index", opts.Index,
+	).Info("Applying patch")
+
+	args := []string{"apply"}
+	if opts.Check {
+		args = append(args, "--check")
+	}
+	if opts.ThreeWay {
+		args = append(args, "--3way")
+	}
+	if opts.Index {
+		args = append(args, "--index")
+	}
+	args = append(args, "--", file)
+
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) ContinueMailbox() error {
+	h.log.Info("Continuing mailbox application")
+
+	return h.mailboxConflict(h.executeNO("am", "--continue"))
+}
+
+func (h *handlerImpl) FormatPatch(rng, outputDir string) (files []string, err error) {
+	h.log.With(
+		"range", rng,
+		"output-dir", outputDir,
+	).Info("Formatting patches")
+
+	out, err := h.execute("format-patch", "--output-directory", outputDir, "--end-of-options", rng)
+	if err != nil {
+		return
+	}
+
+	files = splitLines(out)
+	return
+}
+
+func (h *handlerImpl) FormatPatchTo(w io.Writer, rng string) error {
+	h.log.Info("Formatting patches to writer", "range", rng)
+
+	return h.executeTo(w, "format-patch", "--stdout", "--end-of-options", rng)
+}
+
+// mailboxConflict turns a failure of `git am` into a PatchConflictError,
+// if a patch is being applied.
+func (h *handlerImpl) mailboxConflict(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	raw, rerr := h.execute("am", "--show-current-patch=raw")
+	if rerr != nil {
+		return err
+	}
+
+	perr := &PatchConflictError{Err: err}
+	if entries, perr2 := ParsePatches(bytes.NewReader(raw)); perr2 == nil && len(entries) > 0 {
+		perr.Subject = entries[0].Subject
+	}
+
+	if out, derr := h.execute("diff", "--name-only", "-z", "--diff-filter=U"); derr == nil {
+		perr.Files = splitNUL(out)
+	}
+
+	return perr
+}
+
+// ParsePatches parses patches in mbox format, as produced by FormatPatch.
+func ParsePatches(r io.Reader) (list []PatchEntry, err error) {
+	var messages [][]byte
+	var hashes []string
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+
+	var current *bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := mboxFromLine.FindStringSubmatch(line); m != nil {
+			if current != nil {
+				messages = append(messages, current.Bytes())
+			}
+			current = &bytes.Buffer{}
+			hashes = append(hashes, m[1])
+			continue
+		}
+
+		if current == nil {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if current != nil {
+		messages = append(messages, current.Bytes())
+	}
+
+	if len(messages) == 0 {
+		err = errors.New("no patches found")
+		return
+	}
+
+	for i, m := range messages {
+		var entry PatchEntry
+		if entry, err = parsePatch(m); err != nil {
+			err = fmt.Errorf("patch %d: %w", i+1, err)
+			return
+		}
+		entry.Hash = hashes[i]
+		list = append(list, entry)
+	}
+
+	return
+}
+
+func parsePatch(m []byte) (entry PatchEntry, err error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(m))
+	if err != nil {
+		return
+	}
+
+	dec := &mime.WordDecoder{}
+
+	if from := msg.Header.Get("From"); from != "" {
+		addr, aerr := mail.ParseAddress(from)
+		if aerr != nil {
+			err = fmt.Errorf("invalid author %q: %w", from, aerr)
+			return
+		}
+		entry.Author = addr.Name
+		entry.Email = addr.Address
+	}
+
+	if entry.Timestamp, err = msg.Header.Date(); err != nil {
+		return
+	}
+
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return
+	}
+	entry.Subject = patchSubjTags.ReplaceAllString(subject, "")
+
+	body, err := io.ReadAll(msg.Body)
+	if err != nil {
+		return
+	}
+
+	text := string(body)
+	message, rest, _ := strings.Cut(text, "\n---\n")
+	if strings.HasPrefix(text, "---\n") {
+		message, rest = "", text[len("---\n"):]
+	}
+	entry.Body = strings.TrimSpace(message)
+
+	if i := strings.Index(rest, "diff --git "); i >= 0 {
+		rest = rest[i:]
+	}
+	if i := strings.LastIndex(rest, "\n-- \n"); i >= 0 {
+		rest = rest[:i+1]
+	}
+	entry.Diff = rest
+
+	return
+}
@@ -0,0 +1,183 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests"
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestParsePatches(t *testing.T) {
+	mbox := `From 6d2bbb38063c81eec8369fd27f5ef711cddd69c9 Mon Sep 17 00:00:00 2001
+From: =?UTF-8?q?J=C3=B6hn=20Doe?= <john@example.com>
+Date: Sun, 18 Oct 2026 22:47:43 +0000
+Subject: [PATCH 1/2] =?UTF-8?q?=C3=BCn=C3=AFcode=20subject=20that=20is=20quite?=
+ =?UTF-8?q?=20long?=
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: 8bit
+
+Body: with a colon
+---
+ f | 1 +
+ 1 file changed, 1 insertion(+)
+
+diff --git a/f b/f
+index 7898192..422c2b7 100644
+--- a/f
++++ b/f
+@@ -1 +1,2 @@
+ a
++b
+-- 
+2.39.5
+
+From 1b5adc6a082abde23abe4b6a16bc38a8849851c0 Mon Sep 17 00:00:00 2001
+From: Jane Doe <jane@example.com>
+Date: Mon, 19 Oct 2026 10:00:00 +0200
+Subject: [PATCH 2/2] Second change
+
+---
+ f | 1 +
+ 1 file changed, 1 insertion(+)
+
+diff --git a/f b/f
+index 422c2b7..de98044 100644
+--- a/f
++++ b/f
+@@ -1,2 +1,3 @@
+ a
+ b
++c
+-- 
+2.39.5
+`
+
+	list, err := ParsePatches(strings.NewReader(mbox))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+
+	assert.Equal(t, "6d2bbb38063c81eec8369fd27f5ef711cddd69c9", list[0].Hash)
+	assert.Equal(t, "Jöhn Doe", list[0].Author)
+	assert.Equal(t, "john@example.com", list[0].Email)
+	assert.Equal(t, int64(1792363663), list[0].Timestamp.Unix())
+	assert.Equal(t, "ünïcode subject that is quite long", list[0].Subject)
+	assert.Equal(t, "Body: with a colon", list[0].Body)
+	assert.Equal(t, true, strings.HasPrefix(list[0].Diff, "diff --git a/f b/f\n"))
+	assert.Equal(t, true, strings.HasSuffix(list[0].Diff, "+b\n"))
+
+	assert.Equal(t, "Second change", list[1].Subject)
+	assert.Equal(t, "", list[1].Body)
+
+	_, err = ParsePatches(strings.NewReader("not a patch"))
+	assert.Error(t, err)
+}
+
+func TestPatches(t *testing.T) {
+	remote := tests.NewTempDir(t)
+	defer os.RemoveAll(remote)
+
+	_, err := exec.Command("git", "init", "--bare", remote).CombinedOutput()
+	assert.NoError(t, err)
+
+	g1, dir1 := newTestRepo(t, "main")
+	defer os.RemoveAll(dir1)
+
+	err = g1.SetRemote("origin", remote)
+	assert.NoError(t, err)
+
+	file := "file.txt"
+	err = os.WriteFile(filepath.Join(dir1, file), []byte("a\n"), 0644)
+	assert.NoError(t, err)
+
+	err = g1.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	err = g1.Push("origin", "main")
+	assert.NoError(t, err)
+
+	for _, content := range []string{"a\nb\n", "a\nb\nc\n"} {
+		err = os.WriteFile(filepath.Join(dir1, file), []byte(content), 0644)
+		assert.NoError(t, err)
+
+		err = g1.CommitFiles([]string{file}, "Add line")
+		assert.NoError(t, err)
+	}
+
+	out := tests.NewTempDir(t)
+	defer os.RemoveAll(out)
+
+	files, err := g1.FormatPatch("origin/main..main", out)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(files))
+
+	buf := &bytes.Buffer{}
+	err = g1.FormatPatchTo(buf, "origin/main..main")
+	assert.NoError(t, err)
+
+	entries, err := ParsePatches(buf)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(entries))
+	assert.Equal(t, "Add line", entries[0].Subject)
+
+	// clean application
+
+	g2, dir2 := newTestRepo(t, "")
+	defer os.RemoveAll(dir2)
+
+	err = g2.SetRemote("origin", remote)
+	assert.NoError(t, err)
+
+	err = g2.Pull("origin", "main")
+	assert.NoError(t, err)
+
+	err = g2.ApplyPatch(files[0], ApplyOptions{Check: true})
+	assert.NoError(t, err)
+
+	err = g2.ApplyPatch(files[1], ApplyOptions{Check: true})
+	assert.Error(t, err)
+
+	err = g2.ApplyMailbox(files)
+	assert.NoError(t, err)
+
+	log, err := g2.Log(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(log))
+
+	// conflicting application
+
+	g3, dir3 := newTestRepo(t, "")
+	defer os.RemoveAll(dir3)
+
+	err = g3.SetRemote("origin", remote)
+	assert.NoError(t, err)
+
+	err = g3.Pull("origin", "main")
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir3, file), []byte("a\nx\n"), 0644)
+	assert.NoError(t, err)
+
+	err = g3.CommitFiles([]string{file}, "Diverge")
+	assert.NoError(t, err)
+
+	err = g3.ApplyMailbox(files, true)
+
+	var conflict *PatchConflictError
+	assert.Equal(t, true, errors.As(err, &conflict))
+	assert.Equal(t, "Add line", conflict.Subject)
+	assert.Equal(t, []string{file}, conflict.Files)
+
+	err = g3.AbortMailbox()
+	assert.NoError(t, err)
+
+	log, err = g3.Log(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "Diverge", log[0].Subject)
+}
@@ -0,0 +1,473 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// DefaultPolicyFile is the file, relative to the root directory, the
+// policy checked by CheckPolicy is loaded from.
+const DefaultPolicyFile = ".gitpolicy.json"
+
+// DefaultMaxFileSize is the maximum size of a staged file in DefaultPolicy.
+const DefaultMaxFileSize = 5 << 20
+
+// ErrPolicyViolation is returned when staged files violate the policy.
+var ErrPolicyViolation = errors.New("policy violation")
+
+// PolicyRule defines the kind of rule a staged file violates.
+type PolicyRule string
+
+// Supported policy rules.
+const (
+	RuleMaxFileSize   PolicyRule = "maxFileSize"
+	RuleBinary        PolicyRule = "binary"
+	RuleForbiddenPath PolicyRule = "forbiddenPath"
+	RuleForbiddenName PolicyRule = "forbiddenName"
+	RuleSecret        PolicyRule = "secret"
+)
+
+// SecretPattern defines content that must not be committed.
+type SecretPattern struct {
+	Name string `json:"name"`
+
+	// Pattern is a regular expression, as accepted by regexp, matched
+	// against every added line.
+	Pattern string `json:"pattern"`
+}
+
+// Policy defines the rules checked against staged files.
+//
+// Path patterns are matched against the path relative to the root
+// directory. Those without a slash are matched against the file name
+// only, `*` does not match slashes and `**` matches any number of
+// directories, e.g. `*.exe` or `vendor/**`.
+type Policy struct {
+	// MaxFileSize is the maximum size, in bytes, of a staged file.
+	// Zero means no limit.
+	MaxFileSize int64 `json:"maxFileSize,omitempty"`
+
+	// NoBinary rejects binary files, as detected by git, unless they
+	// match one of AllowedBinaries.
+	NoBinary        bool     `json:"noBinary,omitempty"`
+	AllowedBinaries []string `json:"allowedBinaries,omitempty"`
+
+	// ForbiddenPaths are path patterns that must not be committed.
+	ForbiddenPaths []string `json:"forbiddenPaths,omitempty"`
+
+	// ForbiddenNames are file names that must not be committed in any
+	// directory, e.g. `.env` or `id_rsa`.
+	ForbiddenNames []string `json:"forbiddenNames,omitempty"`
+
+	// Secrets are checked against added lines only, so that existing
+	// content does not block unrelated changes.
+	Secrets []SecretPattern `json:"secrets,omitempty"`
+
+	// Exclude are path patterns skipped by every rule.
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// DefaultPolicy returns the policy used when the repository does not
+// provide one.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MaxFileSize: DefaultMaxFileSize,
+		ForbiddenNames: []string{
+			".env", "id_rsa", "id_dsa", "id_ecdsa", "id_ed25519",
+			".npmrc", ".pypirc", ".netrc", "credentials.json",
+		},
+		Secrets: []SecretPattern{
+			{Name: "private-key", Pattern: `-----BEGIN ([A-Z]+ )?PRIVATE KEY-----`},
+			{Name: "aws-access-key", Pattern: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+			{Name: "github-token", Pattern: `\bgh[pousr]_[0-9A-Za-z]{36,}\b`},
+			{Name: "slack-token", Pattern: `\bxox[abposr]-[0-9A-Za-z-]{10,}\b`},
+			{
+				Name:    "generic-secret",
+				Pattern: `(?i)\b(api[_-]?key|secret|passw(or)?d|token)\b\s*[:=]\s*["'][^"'\s]{8,}["']`,
+			},
+		},
+	}
+}
+
+// LoadPolicy reads a policy from the given JSON file.
+func LoadPolicy(file string) (*Policy, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Policy{}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", file, err)
+	}
+
+	if _, err := p.compileSecrets(); err != nil {
+		return nil, fmt.Errorf("invalid policy %s: %w", file, err)
+	}
+
+	return p, nil
+}
+
+func (p *Policy) compileSecrets() ([]*regexp.Regexp, error) {
+	list := make([]*regexp.Regexp, 0, len(p.Secrets))
+	for _, s := range p.Secrets {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secret %q: %w", s.Name, err)
+		}
+		list = append(list, re)
+	}
+	return list, nil
+}
+
+// PolicyViolation defines a staged file breaking a policy rule.
+type PolicyViolation struct {
+	Path string     `json:"path"`
+	Rule PolicyRule `json:"rule"`
+
+	// Line is the line number of the offending content, if any.
+	Line int `json:"line,omitempty"`
+
+	Reason string `json:"reason"`
+}
+
+// PolicyReport defines the violations found by CheckPolicy, by path.
+type PolicyReport []PolicyViolation
+
+// Err returns an error wrapping ErrPolicyViolation and listing the
+// violations, or nil if there are none.
+func (r PolicyReport) Err() error {
+	if len(r) == 0 {
+		return nil
+	}
+
+	reasons := make([]string, 0, len(r))
+	for _, v := range r {
+		where := v.Path
+		if v.Line > 0 {
+			where += ":" + strconv.Itoa(v.Line)
+		}
+		reasons = append(reasons, where+": "+v.Reason)
+	}
+
+	return fmt.Errorf("%w: %s", ErrPolicyViolation, strings.Join(reasons, "; "))
+}
+
+// Table writes the report as a table, e.g. for a pre-commit hook.
+func (r PolicyReport) Table(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PATH\tLINE\tRULE\tREASON")
+	for _, v := range r {
+		line := "-"
+		if v.Line > 0 {
+			line = strconv.Itoa(v.Line)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", v.Path, line, v.Rule, v.Reason)
+	}
+	return tw.Flush()
+}
+
+// stagedFile defines a file to be checked by CheckPolicy.
+type stagedFile struct {
+	path   string
+	blob   string
+	binary bool
+}
+
+func (h *handlerImpl) CheckPolicy(policy *Policy) (report PolicyReport, err error) {
+	h.log.Info("Checking staged files against policy", "custom", policy != nil)
+
+	if policy == nil {
+		policy, err = h.repoPolicy()
+		if err != nil {
+			return
+		}
+	}
+
+	secrets, err := policy.compileSecrets()
+	if err != nil {
+		return
+	}
+
+	files, err := h.stagedFiles()
+	if err != nil {
+		return
+	}
+	files = slices.DeleteFunc(files, func(f stagedFile) bool {
+		return matchAnyPath(policy.Exclude, f.path)
+	})
+	if len(files) == 0 {
+		return
+	}
+
+	sizes, err := h.blobSizes(files)
+	if err != nil {
+		return
+	}
+
+	for _, f := range files {
+		name := path.Base(f.path)
+		if slices.Contains(policy.ForbiddenNames, name) {
+			report = append(report, PolicyViolation{
+				Path:   f.path,
+				Rule:   RuleForbiddenName,
+				Reason: fmt.Sprintf("file name %q is forbidden", name),
+			})
+		}
+
+		for _, p := range policy.ForbiddenPaths {
+			if matchPath(p, f.path) {
+				report = append(report, PolicyViolation{
+					Path:   f.path,
+					Rule:   RuleForbiddenPath,
+					Reason: fmt.Sprintf("path matches forbidden pattern %q", p),
+				})
+				break
+			}
+		}
+
+		if policy.MaxFileSize > 0 && sizes[f.blob] > policy.MaxFileSize {
+			report = append(report, PolicyViolation{
+				Path:   f.path,
+				Rule:   RuleMaxFileSize,
+				Reason: fmt.Sprintf("size %d exceeds the maximum of %d bytes", sizes[f.blob], policy.MaxFileSize),
+			})
+		}
+
+		if policy.NoBinary && f.binary && !matchAnyPath(policy.AllowedBinaries, f.path) {
+			report = append(report, PolicyViolation{
+				Path:   f.path,
+				Rule:   RuleBinary,
+				Reason: "binary files are not allowed",
+			})
+		}
+	}
+
+	if len(secrets) > 0 {
+		var found PolicyReport
+		if found, err = h.findSecrets(files, policy.Secrets, secrets); err != nil {
+			return
+		}
+		report = append(report, found...)
+	}
+
+	slices.SortStableFunc(report, func(a, b PolicyViolation) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return
+}
+
+// repoPolicy returns the policy in the repository's DefaultPolicyFile,
+// or DefaultPolicy if there is none.
+func (h *handlerImpl) repoPolicy() (*Policy, error) {
+	p, err := LoadPolicy(filepath.Join(h.root, DefaultPolicyFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return DefaultPolicy(), nil
+	}
+	return p, err
+}
+
+// stagedFiles returns the files added or modified in the index.
+func (h *handlerImpl) stagedFiles() ([]stagedFile, error) {
+	out, err := h.execute("diff", "--cached", "--raw", "--numstat", "-z", "--no-renames", "--no-abbrev", "--diff-filter=d")
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: the raw records, `:mode mode blob blob status NUL path NUL`,
+	// come first, followed by the numstat ones, `added TAB deleted TAB path NUL`
+	var files []stagedFile
+	index := map[string]int{}
+	fields := splitNUL(out)
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.HasPrefix(f, ":") {
+			if i+1 >= len(fields) {
+				break
+			}
+			i++
+
+			meta := strings.Fields(f)
+			if len(meta) < 5 || meta[1] == "160000" {
+				// NOTE: submodules are not files
+				continue
+			}
+
+			index[fields[i]] = len(files)
+			files = append(files, stagedFile{path: fields[i], blob: meta[3]})
+			continue
+		}
+
+		parts := strings.SplitN(f, "\t", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		if idx, ok := index[parts[2]]; ok && parts[0] == "-" {
+			files[idx].binary = true
+		}
+	}
+
+	return files, nil
+}
+
+// blobSizes returns the size of each staged file's blob.
+func (h *handlerImpl) blobSizes(files []stagedFile) (map[string]int64, error) {
+	var in strings.Builder
+	for _, f := range files {
+		in.WriteString(f.blob + "\n")
+	}
+
+	out, err := h.executeStdin(strings.NewReader(in.String()), "cat-file", "--batch-check=%(objectname) %(objectsize)")
+	if err != nil {
+		return nil, err
+	}
+
+	sizes := map[string]int64{}
+	for _, l := range splitLines(out) {
+		hash, size, found := strings.Cut(l, " ")
+		if !found {
+			continue
+		}
+		if n, err := strconv.ParseInt(size, 10, 64); err == nil {
+			sizes[hash] = n
+		}
+	}
+
+	return sizes, nil
+}
+
+// findSecrets checks the lines added to the given files against the
+// secret patterns.
+func (h *handlerImpl) findSecrets(files []stagedFile, patterns []SecretPattern, secrets []*regexp.Regexp) (report PolicyReport, err error) {
+	checked := map[string]bool{}
+	for _, f := range files {
+		if !f.binary {
+			checked[f.path] = true
+		}
+	}
+
+	out, err := h.execute(
+		"-c", "core.quotePath=false",
+		"diff", "--cached", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames",
+		"--diff-filter=d", "--src-prefix=a/", "--dst-prefix=b/",
+	)
+	if err != nil {
+		return
+	}
+
+	// NOTE: left counts the added lines still to come in the current
+	// hunk, so that they are not mistaken for headers
+	var file string
+	var line, left int
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(nil, 16<<20)
+	for sc.Scan() {
+		l := sc.Text()
+		switch {
+		case left == 0 && strings.HasPrefix(l, "+++ "):
+			file = diffPath(strings.TrimPrefix(l, "+++ "))
+		case left == 0 && strings.HasPrefix(l, "@@ "):
+			line, left = hunkRange(l)
+		case left > 0 && strings.HasPrefix(l, "+"):
+			left--
+			if checked[file] {
+				for i, re := range secrets {
+					if re.MatchString(l[1:]) {
+						report = append(report, PolicyViolation{
+							Path:   file,
+							Rule:   RuleSecret,
+							Line:   line,
+							Reason: fmt.Sprintf("content matches secret pattern %q", patterns[i].Name),
+						})
+					}
+				}
+			}
+			line++
+		}
+	}
+
+	err = sc.Err()
+	return
+}
+
+// diffPath returns the path of a `+++ b/path` diff header.
+func diffPath(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			s = u
+		}
+	}
+	return strings.TrimPrefix(s, "b/")
+}
+
+// hunkRange returns the first line and the number of lines of the new
+// side of a `@@ -a,b +c,d @@` hunk header.
+func hunkRange(s string) (start, count int) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return
+	}
+
+	first, n, found := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+	start, _ = strconv.Atoi(first)
+	count = 1
+	if found {
+		count, _ = strconv.Atoi(n)
+	}
+	return
+}
+
+// matchAnyPath tells whether the path matches any of the patterns.
+func matchAnyPath(patterns []string, name string) bool {
+	return slices.ContainsFunc(patterns, func(p string) bool {
+		return matchPath(p, name)
+	})
+}
+
+// matchPath tells whether the path matches the pattern, as described
+// by Policy.
+func matchPath(pattern, name string) bool {
+	pattern = strings.TrimPrefix(pattern, "/")
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := len(name); i >= 0; i-- {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
@@ -0,0 +1,180 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	write := func(name string, content []byte) string {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		assert.NoError(t, err)
+		err = os.WriteFile(path, content, 0644)
+		assert.NoError(t, err)
+		return path
+	}
+
+	existing := write("config.go", []byte("package main\n\nconst token = \"abcdefgh12345678\"\n"))
+	err := g.CommitFiles([]string{existing}, "Initial commit")
+	assert.NoError(t, err)
+
+	files := []string{
+		write("big.txt", bytes.Repeat([]byte("x"), 2048)),
+		write("image.bin", []byte{0, 1, 2, 3, 0, 255}),
+		write("assets/logo.png", []byte{0, 1, 2, 3}),
+		write("sub/.env", []byte("KEY=value\n")),
+		write("vendor/lib/lib.go", []byte("package lib\n")),
+		write("keys.txt", []byte("line one\n++ not a header\nAKIAABCDEFGHIJKLMNOP\n")),
+		write("skipped/big.txt", bytes.Repeat([]byte("x"), 2048)),
+		write("config.go", []byte("package main\n\nconst token = \"abcdefgh12345678\"\n\nvar other = 1\n")),
+	}
+	err = g.AddToStaging(files)
+	assert.NoError(t, err)
+
+	policy := &Policy{
+		MaxFileSize:     1024,
+		NoBinary:        true,
+		AllowedBinaries: []string{"*.png"},
+		ForbiddenPaths:  []string{"vendor/**"},
+		ForbiddenNames:  []string{".env"},
+		Secrets:         DefaultPolicy().Secrets,
+		Exclude:         []string{"skipped/**"},
+	}
+
+	report, err := g.CheckPolicy(policy)
+	assert.NoError(t, err)
+
+	type violation struct {
+		path string
+		rule PolicyRule
+		line int
+	}
+	var got []violation
+	for _, v := range report {
+		got = append(got, violation{v.Path, v.Rule, v.Line})
+	}
+	assert.Equal(t, []violation{
+		{"big.txt", RuleMaxFileSize, 0},
+		{"image.bin", RuleBinary, 0},
+		{"keys.txt", RuleSecret, 3},
+		{"sub/.env", RuleForbiddenName, 0},
+		{"vendor/lib/lib.go", RuleForbiddenPath, 0},
+	}, got)
+
+	err = report.Err()
+	assert.Equal(t, true, errors.Is(err, ErrPolicyViolation))
+	assert.Equal(t, true, strings.Contains(err.Error(), "keys.txt:3"))
+	assert.Equal(t, false, strings.Contains(err.Error(), "AKIA"))
+
+	var b bytes.Buffer
+	err = report.Table(&b)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(splitLines(b.Bytes())))
+
+	t.Run("Commit", func(t *testing.T) {
+		before, err := g.LatestHash()
+		assert.NoError(t, err)
+
+		_, err = g.CommitWithOptions(CommitOptions{Message: "Blocked", Policy: policy})
+		assert.Equal(t, true, errors.Is(err, ErrPolicyViolation))
+
+		after, err := g.LatestHash()
+		assert.NoError(t, err)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("Repository policy", func(t *testing.T) {
+		report, err := g.CheckPolicy(nil)
+		assert.NoError(t, err)
+
+		rules := map[PolicyRule]int{}
+		for _, v := range report {
+			rules[v.Rule]++
+		}
+		assert.Equal(t, 1, rules[RuleForbiddenName])
+		assert.Equal(t, 1, rules[RuleSecret])
+		assert.Equal(t, 0, rules[RuleMaxFileSize])
+
+		write(DefaultPolicyFile, []byte(`{"maxFileSize": 100, "exclude": ["skipped/**"]}`))
+		report, err = g.CheckPolicy(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(report))
+		for _, v := range report {
+			assert.Equal(t, RuleMaxFileSize, v.Rule)
+		}
+
+		write(DefaultPolicyFile, []byte(`{"secrets": [{"name": "bad", "pattern": "("}]}`))
+		_, err = g.CheckPolicy(nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestCommitPolicyUnstages(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file.txt")
+	err := os.WriteFile(file, []byte("v1"), 0644)
+	assert.NoError(t, err)
+	err = g.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	env := filepath.Join(dir, ".env")
+	err = os.WriteFile(env, []byte("KEY=value\n"), 0644)
+	assert.NoError(t, err)
+
+	_, err = g.CommitWithOptions(CommitOptions{
+		Message: "Blocked",
+		Files:   []string{env},
+		Policy:  &Policy{ForbiddenNames: []string{".env"}},
+	})
+	assert.Equal(t, true, errors.Is(err, ErrPolicyViolation))
+
+	out, err := g.(*handlerImpl).execute("diff", "--cached", "--name-only")
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(out))
+
+	_, err = g.CommitWithOptions(CommitOptions{
+		Message: "Invalid policy",
+		Files:   []string{env},
+		Policy:  &Policy{Secrets: []SecretPattern{{Name: "bad", Pattern: "("}}},
+	})
+	assert.Error(t, err)
+
+	out, err = g.(*handlerImpl).execute("diff", "--cached", "--name-only")
+	assert.NoError(t, err)
+	assert.Equal(t, "", string(out))
+}
+
+func TestMatchPath(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"*.exe", "bin/tool.exe", true},
+		{"*.exe", "tool.exe.txt", false},
+		{"vendor/**", "vendor/a/b.go", true},
+		{"vendor/**", "src/vendor/a.go", false},
+		{"**/testdata/*.bin", "a/b/testdata/x.bin", true},
+		{"**/testdata/*.bin", "testdata/x.bin", true},
+		{"/docs/*.pdf", "docs/a.pdf", true},
+		{"docs/*.pdf", "docs/sub/a.pdf", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.path, func(t *testing.T) {
+			assert.Equal(t, tc.want, matchPath(tc.pattern, tc.path))
+		})
+	}
+}
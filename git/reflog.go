@@ -0,0 +1,243 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReflogEntry defines an entry in a reference's reflog.
+type ReflogEntry struct {
+	// Selector is the entry's selector, e.g. `HEAD@{0}`.
+	Selector string `json:"selector"`
+
+	// OldHash is the reference's value before the update, all zeros if
+	// the update created it. With ref backends other than files, it is
+	// taken from the previous entry, so it is empty for the oldest one.
+	OldHash string `json:"oldHash"`
+	NewHash string `json:"newHash"`
+
+	// Action is the operation that updated the reference, e.g. `commit`,
+	// `checkout` or `reset`.
+	Action string `json:"action"`
+
+	Message string `json:"message"`
+
+	Committer string    `json:"committer"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+func (h *handlerImpl) LostCommits() ([]LogEntry, error) {
+	h.log.Info("Looking for lost commits")
+
+	out, err := h.execute("fsck", "--unreachable", "--no-reflogs", "--no-progress")
+	if err != nil {
+		return nil, err
+	}
+
+	var hashes []string
+	for _, l := range splitLines(out) {
+		if hash, ok := strings.CutPrefix(l, "unreachable commit "); ok {
+			hashes = append(hashes, hash)
+		}
+	}
+
+	if len(hashes) == 0 {
+		return []LogEntry{}, nil
+	}
+
+	args := []string{"log", "--no-walk=sorted"}
+	args = append(args, hashes...)
+
+	return h.logEntries(args...)
+}
+
+func (h *handlerImpl) RecoverCommit(hash, branch string) error {
+	h.log.With(
+		"hash", hash,
+		"branch", branch,
+	).Info("Recovering commit into new branch")
+
+	return h.executeNO("branch", "--end-of-options", branch, hash)
+}
+
+func (h *handlerImpl) Reflog(ref string) ([]ReflogEntry, error) {
+	h.log.Info("Reading reflog", "ref", ref)
+
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	name := ref
+	if ref != "HEAD" {
+		out, err := h.execute("rev-parse", "--verify", "--quiet", "--symbolic-full-name", ref)
+		if err != nil {
+			return nil, err
+		}
+
+		name = strings.TrimSuffix(string(out), "\n")
+		if name == "" {
+			return nil, fmt.Errorf("%q is not a reference", ref)
+		}
+	}
+
+	storage, err := h.executeConfigGet("config", "--get", "extensions.refStorage")
+	if err != nil && !errors.Is(err, ErrConfigKeyNotFound) {
+		return nil, err
+	}
+
+	var list []ReflogEntry
+	if st := strings.TrimSpace(string(storage)); st == "" || st == "files" {
+		list, err = h.rawReflog(name)
+	} else {
+		list, err = h.showReflog(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		list[i].Selector = fmt.Sprintf("%s@{%d}", ref, i)
+	}
+
+	return list, nil
+}
+
+// rawReflog reads the reflog of the given reference from the files
+// backend, most recent first.
+//
+// NOTE: no `git reflog show` format holds an entry's old value, so the
+// records are parsed directly, from the path reported by git
+func (h *handlerImpl) rawReflog(name string) ([]ReflogEntry, error) {
+	out, err := h.execute("rev-parse", "--git-path", "logs/"+name)
+	if err != nil {
+		return nil, err
+	}
+
+	file := strings.TrimSuffix(string(out), "\n")
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(h.root, file)
+	}
+
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []ReflogEntry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	list, err := parseRawReflog(b)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Reverse(list)
+	return list, nil
+}
+
+// showReflog reads the reflog of the given reference through git, for
+// any ref backend, most recent first.
+func (h *handlerImpl) showReflog(name string) ([]ReflogEntry, error) {
+	out, err := h.execute("reflog", "show", "--format="+reflogFormat, "--date=unix", name, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := parseReflog(out)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range list {
+		if i+1 < len(list) {
+			list[i].OldHash = list[i+1].NewHash
+		}
+	}
+
+	return list, nil
+}
+
+// reflogFormat is the format parsed by parseReflog. With --date=unix,
+// the selector holds the entry's timestamp, e.g. `HEAD@{1700000000}`.
+const reflogFormat = "%H%x1f%gd%x1f%gn <%ge>%x1f%gs"
+
+// parseRawReflog parses the records of a reflog file, oldest first, e.g.
+// `<old> <new> Name <email> 1700000000 +0000\tcommit: message`.
+func parseRawReflog(b []byte) (list []ReflogEntry, err error) {
+	list = []ReflogEntry{}
+	for n, line := range splitLines(b) {
+		head, msg, _ := strings.Cut(line, "\t")
+
+		oldHash, rest, _ := strings.Cut(head, " ")
+		newHash, ident, _ := strings.Cut(rest, " ")
+
+		i := strings.LastIndex(ident, "> ")
+		if i < 0 || oldHash == "" || newHash == "" {
+			err = fmt.Errorf("reflog line %d has less elements than expected", n+1)
+			return
+		}
+
+		entry := ReflogEntry{OldHash: oldHash, NewHash: newHash, Committer: ident[:i+1]}
+
+		ts, _, _ := strings.Cut(ident[i+2:], " ")
+
+		var sec int64
+		if sec, err = strconv.ParseInt(ts, 10, 64); err != nil {
+			err = fmt.Errorf("reflog line %d has an invalid timestamp: %w", n+1, err)
+			return
+		}
+		entry.Timestamp = time.Unix(sec, 0)
+
+		if action, message, found := strings.Cut(msg, ": "); found {
+			entry.Action = action
+			entry.Message = message
+		} else {
+			entry.Message = msg
+		}
+
+		list = append(list, entry)
+	}
+
+	return
+}
+
+// parseReflog parses the output of `git reflog show` formatted with
+// reflogFormat, most recent first.
+func parseReflog(b []byte) (list []ReflogEntry, err error) {
+	list = []ReflogEntry{}
+	for n, line := range splitLines(b) {
+		fields := strings.SplitN(line, "\x1f", 4)
+		if len(fields) < 4 {
+			err = fmt.Errorf("reflog line %d has less elements than expected", n+1)
+			return
+		}
+
+		entry := ReflogEntry{NewHash: fields[0], Committer: fields[2]}
+
+		_, ts, _ := strings.Cut(strings.TrimSuffix(fields[1], "}"), "@{")
+
+		var sec int64
+		if sec, err = strconv.ParseInt(ts, 10, 64); err != nil {
+			err = fmt.Errorf("reflog line %d has an invalid timestamp: %w", n+1, err)
+			return
+		}
+		entry.Timestamp = time.Unix(sec, 0)
+
+		if action, message, found := strings.Cut(fields[3], ": "); found {
+			entry.Action = action
+			entry.Message = message
+		} else {
+			entry.Message = fields[3]
+		}
+
+		list = append(list, entry)
+	}
+
+	return
+}
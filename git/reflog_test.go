@@ -0,0 +1,105 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestReflog(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := "file.txt"
+	err := os.WriteFile(filepath.Join(dir, file), []byte{}, 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	first, err := g.LatestHash(true)
+	assert.NoError(t, err)
+
+	err = os.WriteFile(filepath.Join(dir, file), []byte("test"), 0644)
+	assert.NoError(t, err)
+
+	err = g.CommitFiles([]string{file}, "Some change: with colon")
+	assert.NoError(t, err)
+
+	second, err := g.LatestHash(true)
+	assert.NoError(t, err)
+
+	list, err := g.Reflog("main")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(list))
+
+	assert.Equal(t, "main@{0}", list[0].Selector)
+	assert.Equal(t, first, list[0].OldHash)
+	assert.Equal(t, second, list[0].NewHash)
+	assert.Equal(t, "commit", list[0].Action)
+	assert.Equal(t, "Some change: with colon", list[0].Message)
+
+	assert.Equal(t, false, list[0].Timestamp.IsZero())
+	assert.Equal(t, true, strings.HasSuffix(list[0].Committer, ">"))
+
+	assert.Equal(t, "main@{1}", list[1].Selector)
+	assert.Equal(t, "commit (initial)", list[1].Action)
+	assert.Equal(t, strings.Repeat("0", len(first)), list[1].OldHash)
+	assert.Equal(t, first, list[1].NewHash)
+
+	lost, err := g.LostCommits()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(lost))
+
+	_, err = g.Reset(ResetHard, first)
+	assert.NoError(t, err)
+
+	list, err = g.Reflog("")
+	assert.NoError(t, err)
+	assert.Equal(t, "HEAD@{0}", list[0].Selector)
+	assert.Equal(t, "reset", list[0].Action)
+	assert.Equal(t, second, list[0].OldHash)
+
+	lost, err = g.LostCommits()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(lost))
+	assert.Equal(t, second, lost[0].Hash)
+	assert.Equal(t, "Some change: with colon", lost[0].Subject)
+
+	err = g.RecoverCommit(lost[0].Hash, "recovered")
+	assert.NoError(t, err)
+
+	hash, err := g.ResolveRev("recovered")
+	assert.NoError(t, err)
+	assert.Equal(t, second, hash)
+
+	lost, err = g.LostCommits()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(lost))
+
+	_, err = g.Reflog("does-not-exist")
+	assert.Error(t, err)
+
+	// NOTE: without --rewrite, the remaining entries keep their old values
+	out, err := exec.Command("git", "reflog", "delete", "HEAD@{1}").CombinedOutput()
+	assert.NoError(t, err, string(out))
+
+	list, err = g.Reflog("")
+	assert.NoError(t, err)
+	assert.Equal(t, "reset", list[0].Action)
+	assert.Equal(t, second, list[0].OldHash)
+	assert.Equal(t, first, list[1].NewHash)
+
+	shown, err := g.(*handlerImpl).showReflog("HEAD")
+	assert.NoError(t, err)
+	assert.Equal(t, len(list), len(shown))
+	for i := range shown {
+		assert.Equal(t, list[i].NewHash, shown[i].NewHash)
+		assert.Equal(t, list[i].Timestamp, shown[i].Timestamp)
+		assert.Equal(t, list[i].Message, shown[i].Message)
+	}
+}
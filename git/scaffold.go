@@ -0,0 +1,172 @@
+package git
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// DefaultScaffoldMessage is the message of the commit created by Scaffold.
+const DefaultScaffoldMessage = "Initial commit"
+
+// scaffoldTemplates holds the default template set used by Scaffold.
+//
+//go:embed all:templates/default
+var scaffoldTemplates embed.FS
+
+// ScaffoldOptions defines the options supported by Scaffold.
+//
+// A template set is a file system whose `hooks` directory holds the
+// hooks to install, and whose other files, e.g. `.gitignore` or
+// `.gitattributes`, are written to the working tree. Files with the
+// `.tmpl` extension are rendered with text/template, and written without it.
+type ScaffoldOptions struct {
+	// FS is the template set. It defaults to the embedded one, which
+	// provides a `.gitignore`, a `.gitattributes` and a pre-commit hook
+	// rejecting whitespace errors.
+	FS fs.FS `json:"-"`
+
+	// Data is passed to the `.tmpl` files.
+	Data any `json:"data,omitempty"`
+
+	// Message is the message of the commit adding the working tree files.
+	// It defaults to DefaultScaffoldMessage.
+	Message string `json:"message,omitempty"`
+
+	// NoCommit leaves the working tree files uncommitted.
+	NoCommit bool `json:"noCommit,omitempty"`
+
+	// Overwrite replaces existing files, which are kept otherwise.
+	Overwrite bool `json:"overwrite,omitempty"`
+}
+
+func (h *handlerImpl) Scaffold(opts ScaffoldOptions) error {
+	h.log.With(
+		"custom-fs", opts.FS != nil,
+		"message", opts.Message,
+		"no-commit", opts.NoCommit,
+		"overwrite", opts.Overwrite,
+	).Info("Scaffolding repository")
+
+	tfs := opts.FS
+	if tfs == nil {
+		sub, err := fs.Sub(scaffoldTemplates, "templates/default")
+		if err != nil {
+			return err
+		}
+		tfs = sub
+	}
+	if opts.Message == "" {
+		opts.Message = DefaultScaffoldMessage
+	}
+
+	out, err := h.execute("rev-parse", "--is-bare-repository", "--git-path", "hooks")
+	if err != nil {
+		return err
+	}
+
+	lines := splitLines(out)
+	if len(lines) < 2 {
+		return fmt.Errorf("unexpected rev-parse output: %q", out)
+	}
+
+	bare := lines[0] == "true"
+	hooksDir := lines[1]
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(h.root, hooksDir)
+	}
+
+	var written []string
+	err = fs.WalkDir(tfs, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		content, err := renderScaffoldFile(tfs, name, opts.Data)
+		if err != nil {
+			return err
+		}
+		target := strings.TrimSuffix(name, ".tmpl")
+
+		if hook, ok := strings.CutPrefix(target, "hooks/"); ok {
+			_, err := writeScaffoldFile(filepath.Join(hooksDir, filepath.FromSlash(hook)), content, 0755, opts.Overwrite)
+			return err
+		}
+
+		// NOTE: a bare repository has no working tree to scaffold
+		if bare {
+			return nil
+		}
+
+		file := filepath.Join(h.root, filepath.FromSlash(target))
+		ok, err := writeScaffoldFile(file, content, 0644, opts.Overwrite)
+		if ok {
+			written = append(written, file)
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if opts.NoCommit || len(written) == 0 {
+		return nil
+	}
+
+	_, err = h.CommitWithOptions(CommitOptions{Message: opts.Message, Files: written})
+	return err
+}
+
+// renderScaffoldFile returns the content of a template set's file,
+// rendered if it is a `.tmpl` one.
+func renderScaffoldFile(tfs fs.FS, name string, data any) ([]byte, error) {
+	content, err := fs.ReadFile(tfs, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if path.Ext(name) != ".tmpl" {
+		return content, nil
+	}
+
+	t, err := template.New(name).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// writeScaffoldFile writes the given file, unless it exists and
+// overwrite is not set, and tells whether it was written.
+func writeScaffoldFile(file string, content []byte, perm os.FileMode, overwrite bool) (bool, error) {
+	if !overwrite {
+		if _, err := os.Lstat(file); err == nil {
+			return false, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return false, err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return false, err
+	}
+
+	if err := os.WriteFile(file, content, perm); err != nil {
+		return false, err
+	}
+
+	// NOTE: WriteFile keeps the mode of existing files
+	return true, os.Chmod(file, perm)
+}
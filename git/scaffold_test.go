@@ -0,0 +1,174 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/jwmwalrus/bnp/tests"
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestInitWithOptions(t *testing.T) {
+	newHandler := func(t *testing.T) (*handlerImpl, string) {
+		dir := tests.NewTempDir(t)
+		g, err := NewHandler(dir)
+		assert.NoError(t, err)
+		return g.(*handlerImpl), dir
+	}
+
+	t.Run("Scaffold", func(t *testing.T) {
+		g, dir := newHandler(t)
+		defer os.RemoveAll(dir)
+
+		err := g.InitWithOptions(InitOptions{
+			InitialBranch: "main",
+			Shared:        "group",
+			ObjectFormat:  ObjectFormatSHA256,
+			Scaffold:      &ScaffoldOptions{},
+		})
+		assert.NoError(t, err)
+
+		out, err := g.execute("rev-parse", "--show-object-format")
+		assert.NoError(t, err)
+		assert.Equal(t, "sha256", strings.TrimSpace(string(out)))
+
+		shared, err := g.ConfigAt(ConfigScopeLocal, "core.sharedRepository")
+		assert.NoError(t, err)
+		assert.Equal(t, "1", shared)
+
+		for _, f := range []string{".gitignore", ".gitattributes"} {
+			_, err := os.Stat(filepath.Join(dir, f))
+			assert.NoError(t, err)
+		}
+
+		fi, err := os.Stat(filepath.Join(dir, ".git", "hooks", "pre-commit"))
+		assert.NoError(t, err)
+		assert.Equal(t, true, fi.Mode().Perm()&0100 != 0)
+
+		entries, err := g.Log(0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(entries))
+		assert.Equal(t, DefaultScaffoldMessage, entries[0].Subject)
+
+		status, err := g.execute("status", "--porcelain")
+		assert.NoError(t, err)
+		assert.Equal(t, "", string(status))
+
+		// NOTE: the scaffolded pre-commit hook rejects whitespace errors
+		file := filepath.Join(dir, "file.txt")
+		err = os.WriteFile(file, []byte("trailing \n"), 0644)
+		assert.NoError(t, err)
+		err = g.CommitFiles([]string{file}, "Whitespace")
+		assert.Error(t, err)
+	})
+
+	t.Run("Bare", func(t *testing.T) {
+		g, dir := newHandler(t)
+		defer os.RemoveAll(dir)
+
+		err := g.InitWithOptions(InitOptions{Bare: true, Scaffold: &ScaffoldOptions{}})
+		assert.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(dir, "hooks", "pre-commit"))
+		assert.NoError(t, err)
+
+		_, err = os.Stat(filepath.Join(dir, ".gitignore"))
+		assert.Equal(t, true, os.IsNotExist(err))
+	})
+
+	t.Run("SeparateGitDir", func(t *testing.T) {
+		g, dir := newHandler(t)
+		defer os.RemoveAll(dir)
+
+		gitDir := tests.NewTempDir(t)
+		defer os.RemoveAll(gitDir)
+
+		err := g.InitWithOptions(InitOptions{SeparateGitDir: gitDir})
+		assert.NoError(t, err)
+
+		fi, err := os.Stat(filepath.Join(dir, ".git"))
+		assert.NoError(t, err)
+		assert.Equal(t, false, fi.IsDir())
+
+		_, err = os.Stat(filepath.Join(gitDir, "HEAD"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Template", func(t *testing.T) {
+		g, dir := newHandler(t)
+		defer os.RemoveAll(dir)
+
+		tmpl := tests.NewTempDir(t)
+		defer os.RemoveAll(tmpl)
+
+		err := os.WriteFile(filepath.Join(tmpl, "description"), []byte("From template\n"), 0644)
+		assert.NoError(t, err)
+
+		err = g.InitWithOptions(InitOptions{Template: tmpl})
+		assert.NoError(t, err)
+
+		b, err := os.ReadFile(filepath.Join(dir, ".git", "description"))
+		assert.NoError(t, err)
+		assert.Equal(t, "From template\n", string(b))
+	})
+
+	t.Run("Invalid object format", func(t *testing.T) {
+		g, dir := newHandler(t)
+		defer os.RemoveAll(dir)
+
+		err := g.InitWithOptions(InitOptions{ObjectFormat: "md5"})
+		assert.Error(t, err)
+	})
+}
+
+func TestScaffold(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	readme := filepath.Join(dir, "README.md")
+	err := os.WriteFile(readme, []byte("mine\n"), 0644)
+	assert.NoError(t, err)
+
+	tfs := fstest.MapFS{
+		"README.md.tmpl":     {Data: []byte("# {{.Name}}\n")},
+		"docs/index.md":      {Data: []byte("docs\n")},
+		"hooks/commit-msg":   {Data: []byte("#!/bin/sh\nexit 0\n")},
+		"hooks/nested/ok.sh": {Data: []byte("#!/bin/sh\n")},
+	}
+	data := map[string]string{"Name": "project"}
+
+	err = g.Scaffold(ScaffoldOptions{FS: tfs, Data: data, NoCommit: true})
+	assert.NoError(t, err)
+
+	b, err := os.ReadFile(readme)
+	assert.NoError(t, err)
+	assert.Equal(t, "mine\n", string(b))
+
+	_, err = os.Stat(filepath.Join(dir, ".git", "hooks", "commit-msg"))
+	assert.NoError(t, err)
+
+	_, err = g.LatestHash()
+	assert.Error(t, err)
+
+	err = g.Scaffold(ScaffoldOptions{FS: tfs, Data: data, Overwrite: true, Message: "Scaffold"})
+	assert.NoError(t, err)
+
+	b, err = os.ReadFile(readme)
+	assert.NoError(t, err)
+	assert.Equal(t, "# project\n", string(b))
+
+	entries, err := g.Log(0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "Scaffold", entries[0].Subject)
+
+	files, err := g.(*handlerImpl).execute("ls-files")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"README.md", "docs/index.md"}, splitLines(files))
+
+	err = g.Scaffold(ScaffoldOptions{FS: tfs, Overwrite: true})
+	assert.Error(t, err)
+}
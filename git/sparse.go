@@ -0,0 +1,77 @@
+package git
+
+import (
+	"strings"
+)
+
+func (h *handlerImpl) MaterializedPaths() ([]string, error) {
+	h.log.Info("Listing materialized paths")
+
+	out, err := h.execute("ls-files", "-t", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: paths outside of the sparse checkout are tagged with `S`
+	// (skip-worktree)
+	list := []string{}
+	for _, l := range splitNUL(out) {
+		tag, path, found := strings.Cut(l, " ")
+		if !found || tag == "S" {
+			continue
+		}
+		list = append(list, path)
+	}
+
+	return list, nil
+}
+
+func (h *handlerImpl) SparseCheckoutAdd(dirs ...string) error {
+	h.log.Info("Adding directories to sparse checkout", "dirs", dirs)
+
+	args := []string{"sparse-checkout", "add", "--"}
+	args = append(args, dirs...)
+
+	return h.executeNO(args...)
+}
+
+func (h *handlerImpl) SparseCheckoutDisable() error {
+	h.log.Info("Disabling sparse checkout")
+
+	return h.executeNO("sparse-checkout", "disable")
+}
+
+func (h *handlerImpl) SparseCheckoutInit(cone bool) error {
+	h.log.Info("Initializing sparse checkout", "cone", cone)
+
+	if cone {
+		return h.executeNO("sparse-checkout", "set", "--cone")
+	}
+
+	return h.executeNO("sparse-checkout", "set", "--no-cone")
+}
+
+func (h *handlerImpl) SparseCheckoutList() ([]string, error) {
+	h.log.Info("Listing sparse checkout directories")
+
+	out, err := h.execute("sparse-checkout", "list")
+	if err != nil {
+		return nil, err
+	}
+
+	list := splitLines(out)
+	if list == nil {
+		list = []string{}
+	}
+
+	return list, nil
+}
+
+func (h *handlerImpl) SparseCheckoutSet(dirs ...string) error {
+	h.log.Info("Setting sparse checkout directories", "dirs", dirs)
+
+	args := []string{"sparse-checkout", "set", "--"}
+	args = append(args, dirs...)
+
+	return h.executeNO(args...)
+}
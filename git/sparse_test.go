@@ -0,0 +1,93 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests"
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestSparseCheckout(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	files := []string{"top.txt", "a/file.txt", "b/c/file.txt"}
+	for _, f := range files {
+		err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755)
+		assert.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(dir, f), []byte(f), 0644)
+		assert.NoError(t, err)
+	}
+
+	err := g.CommitFiles(files, "Initial commit")
+	assert.NoError(t, err)
+
+	err = g.SetConfig("uploadpack.allowFilter", "true")
+	assert.NoError(t, err)
+
+	base := tests.NewTempDir(t)
+	defer os.RemoveAll(base)
+
+	clone := filepath.Join(base, "clone")
+	g2, err := NewHandler(clone)
+	assert.NoError(t, err)
+
+	err = g2.Clone("file://"+dir, CloneOptions{Filter: "blob:none", Sparse: true})
+	assert.NoError(t, err)
+
+	filter, err := g2.ConfigAt(ConfigScopeLocal, "remote.origin.partialclonefilter")
+	assert.NoError(t, err)
+	assert.Equal(t, "blob:none", filter)
+
+	paths, err := g2.MaterializedPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"top.txt"}, paths)
+
+	err = g2.SparseCheckoutAdd("a")
+	assert.NoError(t, err)
+
+	paths, err = g2.MaterializedPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/file.txt", "top.txt"}, paths)
+
+	content, err := os.ReadFile(filepath.Join(clone, "a/file.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, "a/file.txt", string(content))
+
+	// Blobs outside of the sparse checkout are not fetched
+	out, err := exec.Command("git", "-C", clone, "rev-list", "--objects", "--all", "--missing=print").Output()
+	assert.NoError(t, err)
+	assert.Equal(t, true, strings.Contains("\n"+string(out), "\n?"))
+
+	err = g2.SparseCheckoutSet("b/c")
+	assert.NoError(t, err)
+
+	dirs, err := g2.SparseCheckoutList()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b/c"}, dirs)
+
+	paths, err = g2.MaterializedPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b/c/file.txt", "top.txt"}, paths)
+
+	err = g2.SparseCheckoutDisable()
+	assert.NoError(t, err)
+
+	paths, err = g2.MaterializedPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/file.txt", "b/c/file.txt", "top.txt"}, paths)
+
+	// re-enabling keeps only the top-level files
+
+	err = g2.SparseCheckoutInit(true)
+	assert.NoError(t, err)
+
+	paths, err = g2.MaterializedPaths()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"top.txt"}, paths)
+}
@@ -0,0 +1,380 @@
+package git
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxCommandOutput is the maximum number of bytes of the standard output
+// and error kept in a CommandEvent.
+const MaxCommandOutput = 4096
+
+// ErrDryRun is returned for mutating git invocations, which are only
+// recorded in dry-run mode.
+var ErrDryRun = errors.New("git command not run in dry-run mode")
+
+// CommandEvent defines a git invocation reported to the handler's
+// observers and audit file.
+type CommandEvent struct {
+	// Args are the command's arguments, without the leading `git -C <dir>`.
+	// Passwords in URLs are redacted.
+	Args []string `json:"args"`
+
+	// Dir is the directory the command ran in.
+	Dir string `json:"dir"`
+
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+
+	// ExitCode is the command's exit code, or -1 if it could not be
+	// started.
+	ExitCode int `json:"exitCode"`
+
+	// Stdout and Stderr hold up to MaxCommandOutput bytes of the
+	// command's output, and Truncated tells whether any was left out.
+	Stdout    string `json:"stdout,omitempty"`
+	Stderr    string `json:"stderr,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+
+	// Mutating tells whether the command may modify the repository.
+	Mutating bool `json:"mutating"`
+
+	// DryRun is set when the command was recorded but not executed.
+	DryRun bool `json:"dryRun,omitempty"`
+
+	Error string `json:"error,omitempty"`
+}
+
+// CommandObserver defines the signature of the function called after
+// every git invocation.
+type CommandObserver func(CommandEvent)
+
+// commandTrace holds the observers, dry-run mode and audit file of a handler.
+type commandTrace struct {
+	mu        sync.Mutex
+	observers []CommandObserver
+	dryRun    bool
+	auditFile string
+}
+
+func (h *handlerImpl) AddObserver(obs CommandObserver) {
+	h.log.Info("Adding command observer")
+
+	h.trace.mu.Lock()
+	defer h.trace.mu.Unlock()
+
+	h.trace.observers = append(h.trace.observers, obs)
+}
+
+func (h *handlerImpl) SetAuditFile(file string) error {
+	h.log.Info("Setting audit file", "file", file)
+
+	if file != "" {
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+
+	h.trace.mu.Lock()
+	defer h.trace.mu.Unlock()
+
+	h.trace.auditFile = file
+	return nil
+}
+
+func (h *handlerImpl) SetDryRun(dryRun bool) {
+	h.log.Info("Setting dry-run mode", "dry-run", dryRun)
+
+	h.trace.mu.Lock()
+	defer h.trace.mu.Unlock()
+
+	h.trace.dryRun = dryRun
+}
+
+// isDryRun tells whether the given command must be recorded but not
+// executed.
+func (h *handlerImpl) isDryRun(in []string) bool {
+	h.trace.mu.Lock()
+	dryRun := h.trace.dryRun
+	h.trace.mu.Unlock()
+
+	return dryRun && isMutating(in)
+}
+
+// traced tells whether anybody is interested in command events.
+func (h *handlerImpl) traced() bool {
+	h.trace.mu.Lock()
+	defer h.trace.mu.Unlock()
+
+	return len(h.trace.observers) > 0 || h.trace.auditFile != ""
+}
+
+// report sends the given event to the observers and the audit file.
+func (h *handlerImpl) report(e CommandEvent) {
+	h.trace.mu.Lock()
+	observers := slices.Clone(h.trace.observers)
+	file := h.trace.auditFile
+	h.trace.mu.Unlock()
+
+	for _, obs := range observers {
+		obs(e)
+	}
+
+	if file == "" {
+		return
+	}
+
+	if err := appendAuditEvent(file, e); err != nil {
+		h.log.Error("Failed to write audit event", "file", file, "error", err)
+	}
+}
+
+// newCommandEvent returns the event for a finished command.
+func (h *handlerImpl) newCommandEvent(in []string, start time.Time, stdout, stderr *limitedBuffer, err error) CommandEvent {
+	e := CommandEvent{
+		Args:      redactArgs(in),
+		Dir:       h.root,
+		Start:     start,
+		Duration:  time.Since(start),
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Truncated: stdout.truncated || stderr.truncated,
+		Mutating:  isMutating(in),
+	}
+
+	if err != nil {
+		e.Error = err.Error()
+		e.ExitCode = -1
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			e.ExitCode = exitErr.ExitCode()
+		}
+	}
+
+	return e
+}
+
+// dryRunEvent returns the event for a command recorded but not executed.
+func (h *handlerImpl) dryRunEvent(in []string) CommandEvent {
+	return CommandEvent{
+		Args:     redactArgs(in),
+		Dir:      h.root,
+		Start:    time.Now(),
+		Mutating: true,
+		DryRun:   true,
+	}
+}
+
+// appendAuditEvent appends the event to the audit file as a JSON line.
+func appendAuditEvent(file string, e CommandEvent) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	// NOTE: a single write per event, so that concurrent handlers sharing
+	// the file do not interleave lines
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// limitedBuffer keeps up to MaxCommandOutput bytes written to it, and
+// discards the rest.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	left := MaxCommandOutput - b.buf.Len()
+	if len(p) > left {
+		b.buf.Write(p[:max(left, 0)])
+		b.truncated = true
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// redactArgs returns a copy of the arguments with passwords in URLs
+// replaced.
+func redactArgs(in []string) []string {
+	out := make([]string, len(in))
+	for i, a := range in {
+		out[i] = a
+		if !strings.Contains(a, "://") {
+			continue
+		}
+
+		// NOTE: the URL may be the value of an option, e.g. `--url=...`
+		prefix, raw := "", a
+		if j := strings.Index(a, "="); j >= 0 && j < strings.Index(a, "://") {
+			prefix, raw = a[:j+1], a[j+1:]
+		}
+
+		u, err := url.Parse(raw)
+		if err != nil || u.User == nil {
+			continue
+		}
+		if _, ok := u.User.Password(); ok {
+			out[i] = prefix + u.Redacted()
+		}
+	}
+	return out
+}
+
+// readOnlyCommands are the git commands that never modify the repository.
+var readOnlyCommands = []string{
+	"annotate", "archive", "blame", "cat-file", "check-attr", "check-ignore",
+	"check-mailmap", "check-ref-format", "cherry", "count-objects",
+	"describe", "diff", "diff-files", "diff-index", "diff-tree", "fsck",
+	"for-each-ref", "grep", "help", "log", "ls-files", "ls-remote", "ls-tree",
+	"merge-base", "name-rev", "rev-list", "rev-parse", "shortlog", "show",
+	"show-branch", "show-ref", "status", "var", "verify-commit", "verify-pack",
+	"verify-tag", "version", "whatchanged",
+}
+
+// readOnlySubcommands are the subcommands of git commands that never
+// modify the repository. An empty one stands for no subcommand at all.
+var readOnlySubcommands = map[string][]string{
+	"bisect":          {"log", "visualize", "view"},
+	"bundle":          {"list-heads", "verify"},
+	"commit-graph":    {"verify"},
+	"notes":           {"", "list", "show", "get-ref"},
+	"reflog":          {"", "show", "exists"},
+	"remote":          {"", "show", "get-url"},
+	"sparse-checkout": {"list"},
+	"stash":           {"list", "show"},
+	"worktree":        {"list"},
+}
+
+// optionsWithValue are the options of the commands checked by isMutating
+// that take their value as a separate argument.
+var optionsWithValue = []string{
+	"--ref", "--type", "--default", "--file", "--blob", "--format",
+	"--sort", "--contains", "--no-contains", "--merged", "--no-merged",
+	"--points-at",
+}
+
+// isMutating tells whether the given git command may modify the
+// repository, its configuration or the working tree. Unknown commands
+// are considered mutating.
+func isMutating(in []string) bool {
+	name, rest := commandName(in)
+	if name == "" || slices.Contains(readOnlyCommands, name) {
+		return false
+	}
+
+	flags, positional := splitCommandArgs(rest)
+	hasFlag := func(names ...string) bool {
+		return slices.ContainsFunc(flags, func(f string) bool {
+			f, _, _ = strings.Cut(f, "=")
+			return slices.Contains(names, f)
+		})
+	}
+
+	switch name {
+	case "am":
+		return !hasFlag("--show-current-patch")
+	case "apply":
+		return !hasFlag("--check", "--stat", "--numstat", "--summary") || hasFlag("--apply")
+	case "branch":
+		return len(positional) > 0 || hasFlag(
+			"-d", "-D", "--delete", "-m", "-M", "--move", "-c", "-C", "--copy",
+			"-u", "--set-upstream-to", "--unset-upstream", "--edit-description",
+		)
+	case "clean":
+		return !hasFlag("-n", "--dry-run")
+	case "config":
+		if hasFlag("--add", "--unset", "--unset-all", "--replace-all",
+			"--remove-section", "--rename-section", "-e", "--edit") {
+			return true
+		}
+		if hasFlag("--get", "--get-all", "--get-regexp", "--get-urlmatch",
+			"--get-color", "--get-colorbool", "-l", "--list") {
+			return false
+		}
+		return len(positional) > 1
+	case "format-patch":
+		return !hasFlag("--stdout")
+	case "symbolic-ref":
+		return len(positional) > 1 || hasFlag("-d", "--delete")
+	case "tag":
+		return len(positional) > 0 && !hasFlag("-l", "--list", "-v", "--verify")
+	}
+
+	if subs, ok := readOnlySubcommands[name]; ok {
+		sub := ""
+		if len(positional) > 0 {
+			sub = positional[0]
+		}
+		return !slices.Contains(subs, sub)
+	}
+
+	return true
+}
+
+// commandName returns the name of the given git command, and its
+// arguments, skipping global options, e.g. `-c key=value` or
+// `--no-optional-locks`.
+func commandName(in []string) (name string, rest []string) {
+	for len(in) > 0 && strings.HasPrefix(in[0], "-") {
+		if in[0] == "-c" || in[0] == "-C" {
+			in = in[min(2, len(in)):]
+			continue
+		}
+		in = in[1:]
+	}
+	if len(in) == 0 {
+		return
+	}
+
+	return in[0], in[1:]
+}
+
+// splitCommandArgs splits the arguments of a git command into flags and
+// positional arguments.
+func splitCommandArgs(in []string) (flags, positional []string) {
+	for i := 0; i < len(in); i++ {
+		a := in[i]
+		switch {
+		case a == "--" || a == "--end-of-options":
+			positional = append(positional, in[i+1:]...)
+			return
+		case strings.HasPrefix(a, "-") && a != "-":
+			flags = append(flags, a)
+			if slices.Contains(optionsWithValue, a) && i+1 < len(in) {
+				i++
+			}
+		default:
+			positional = append(positional, a)
+		}
+	}
+	return
+}
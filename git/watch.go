@@ -0,0 +1,335 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// WatchEventType defines the kind of change reported by Watch.
+type WatchEventType string
+
+// Supported watch event types.
+const (
+	// BranchChanged is reported when another branch is checked out, or
+	// HEAD is detached. Old and New are the branch names, empty if detached.
+	BranchChanged WatchEventType = "branchChanged"
+
+	// HeadMoved is reported when HEAD points to another commit, e.g. after
+	// a commit, a reset or a checkout. Old and New are the commit hashes.
+	HeadMoved WatchEventType = "headMoved"
+
+	// IndexChanged is reported when the index is written, e.g. after staging.
+	IndexChanged WatchEventType = "indexChanged"
+
+	// WorktreeDirty is reported when the uncommitted changes, untracked
+	// files included, change. Dirty tells whether there are any left.
+	WorktreeDirty WatchEventType = "worktreeDirty"
+
+	// RemoteRefUpdated is reported for every remote-tracking reference
+	// created, updated or deleted, e.g. after a fetch. Ref is the
+	// reference name, and Old or New is empty if it was created or deleted.
+	RemoteRefUpdated WatchEventType = "remoteRefUpdated"
+
+	// StashChanged is reported when an entry is pushed to or dropped from
+	// the stash. Old and New are the hashes of the latest entry.
+	StashChanged WatchEventType = "stashChanged"
+)
+
+// Default watch intervals.
+const (
+	DefaultWatchInterval = time.Second
+	DefaultWatchDebounce = 300 * time.Millisecond
+)
+
+// maxWatchSettle bounds the number of debounce periods waited for the
+// repository to settle, so that constant activity does not starve events.
+const maxWatchSettle = 10
+
+// WatchOptions defines the options supported by Watch.
+type WatchOptions struct {
+	// Interval is the time between polls. It defaults to DefaultWatchInterval.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Debounce is the time the repository must stay unchanged before
+	// events are reported. It defaults to DefaultWatchDebounce.
+	Debounce time.Duration `json:"debounce,omitempty"`
+
+	// IgnoreWorktree skips checking the working tree, which can be
+	// expensive for large repositories; WorktreeDirty is never reported.
+	IgnoreWorktree bool `json:"ignoreWorktree,omitempty"`
+}
+
+// WatchEvent defines a change in the repository reported by Watch.
+type WatchEvent struct {
+	Type WatchEventType `json:"type"`
+	Ref  string         `json:"ref,omitempty"`
+	Old  string         `json:"old,omitempty"`
+	New  string         `json:"new,omitempty"`
+
+	// Dirty is set for WorktreeDirty events.
+	Dirty bool `json:"dirty,omitempty"`
+
+	Time time.Time `json:"time"`
+}
+
+// watchState defines a snapshot of the state watched by Watch.
+type watchState struct {
+	branch   string
+	head     string
+	index    string
+	worktree string
+	dirty    bool
+	remotes  map[string]string
+	stash    string
+}
+
+func (s *watchState) equal(o *watchState) bool {
+	return s.branch == o.branch && s.head == o.head && s.index == o.index &&
+		s.worktree == o.worktree && s.stash == o.stash && maps.Equal(s.remotes, o.remotes)
+}
+
+// events returns the events describing the changes from s to o.
+func (s *watchState) events(o *watchState, now time.Time) (list []WatchEvent) {
+	if s.branch != o.branch {
+		list = append(list, WatchEvent{Type: BranchChanged, Old: s.branch, New: o.branch, Time: now})
+	}
+	if s.head != o.head {
+		list = append(list, WatchEvent{Type: HeadMoved, Old: s.head, New: o.head, Time: now})
+	}
+	if s.index != o.index {
+		list = append(list, WatchEvent{Type: IndexChanged, Time: now})
+	}
+	if s.worktree != o.worktree {
+		list = append(list, WatchEvent{Type: WorktreeDirty, Dirty: o.dirty, Time: now})
+	}
+
+	refs := slices.Sorted(maps.Keys(s.remotes))
+	for r := range o.remotes {
+		if _, ok := s.remotes[r]; !ok {
+			refs = append(refs, r)
+		}
+	}
+	slices.Sort(refs)
+	for _, r := range refs {
+		if s.remotes[r] != o.remotes[r] {
+			list = append(list, WatchEvent{Type: RemoteRefUpdated, Ref: r, Old: s.remotes[r], New: o.remotes[r], Time: now})
+		}
+	}
+
+	if s.stash != o.stash {
+		list = append(list, WatchEvent{Type: StashChanged, Old: s.stash, New: o.stash, Time: now})
+	}
+	return
+}
+
+func (h *handlerImpl) Watch(ctx context.Context, opts WatchOptions) (<-chan WatchEvent, error) {
+	h.log.With(
+		"interval", opts.Interval,
+		"debounce", opts.Debounce,
+		"ignore-worktree", opts.IgnoreWorktree,
+	).Info("Watching repository")
+
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultWatchInterval
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultWatchDebounce
+	}
+
+	dir, err := h.gitDir()
+	if err != nil {
+		return nil, err
+	}
+
+	last, err := h.watchSnapshot(dir, opts.IgnoreWorktree)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan WatchEvent, 16)
+	go func() {
+		defer close(ch)
+
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, err := h.watchSnapshot(dir, opts.IgnoreWorktree)
+			if err != nil {
+				h.log.Warn("Failed to poll repository state", "error", err)
+				continue
+			}
+			if current.equal(last) {
+				continue
+			}
+
+			// NOTE: wait for bursts of changes, e.g. a rebase, to settle
+			for range maxWatchSettle {
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(opts.Debounce):
+				}
+
+				settled, err := h.watchSnapshot(dir, opts.IgnoreWorktree)
+				if err != nil {
+					break
+				}
+				if settled.equal(current) {
+					break
+				}
+				current = settled
+			}
+
+			for _, e := range last.events(current, time.Now()) {
+				select {
+				case <-ctx.Done():
+					return
+				case ch <- e:
+				}
+			}
+			last = current
+		}
+	}()
+
+	return ch, nil
+}
+
+// watchSnapshot takes a snapshot of the state watched by Watch.
+// Its commands are not traced, so that idle polling does not flood
+// observers or the audit file.
+func (h *handlerImpl) watchSnapshot(gitDir string, ignoreWorktree bool) (*watchState, error) {
+	s := &watchState{remotes: map[string]string{}}
+
+	if ignoreWorktree {
+		if out, err := h.executeUntraced("symbolic-ref", "--quiet", "--short", "HEAD"); err == nil {
+			s.branch = strings.TrimSpace(string(out))
+		}
+		if out, err := h.executeUntraced("rev-parse", "--verify", "--quiet", "HEAD"); err == nil {
+			s.head = strings.TrimSpace(string(out))
+		}
+	} else if err := h.watchStatus(s); err != nil {
+		return nil, err
+	}
+
+	if fi, err := os.Stat(filepath.Join(gitDir, "index")); err == nil {
+		s.index = fmt.Sprintf("%d:%d", fi.ModTime().UnixNano(), fi.Size())
+	}
+
+	out, err := h.executeUntraced("for-each-ref", "--format=%(refname)%00%(objectname)", "refs/remotes", "refs/stash")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range splitLines(out) {
+		ref, hash, found := strings.Cut(l, "\x00")
+		if !found {
+			continue
+		}
+		if ref == "refs/stash" {
+			s.stash = hash
+			continue
+		}
+		s.remotes[ref] = hash
+	}
+
+	return s, nil
+}
+
+// watchStatus fills the branch, HEAD and working tree state from
+// `git status`.
+func (h *handlerImpl) watchStatus(s *watchState) error {
+	// NOTE: optional locks are disabled, so that polling does not
+	// refresh the index, which would be reported as a change
+	out, err := h.executeUntraced("--no-optional-locks", "status", "--porcelain=v2", "--branch", "-z")
+	if err != nil {
+		return err
+	}
+
+	var changes [][]byte
+	var paths []string
+	recs := bytes.Split(out, []byte{0})
+	for i := 0; i < len(recs); i++ {
+		rec := recs[i]
+		if len(rec) == 0 {
+			continue
+		}
+
+		if v, ok := bytes.CutPrefix(rec, []byte("# branch.oid ")); ok {
+			if string(v) != "(initial)" {
+				s.head = string(v)
+			}
+		} else if v, ok := bytes.CutPrefix(rec, []byte("# branch.head ")); ok {
+			if string(v) != "(detached)" {
+				s.branch = string(v)
+			}
+		} else if rec[0] != '#' {
+			changes = append(changes, rec)
+			if path := statusPath(rec); path != "" {
+				paths = append(paths, path)
+			}
+			// NOTE: renames and copies are followed by the original path
+			if rec[0] == '2' && i+1 < len(recs) {
+				i++
+				changes = append(changes, recs[i])
+			}
+		}
+	}
+
+	// NOTE: the records of a file do not change when it is edited again,
+	// so its size and modification time are hashed too. Files inside
+	// untracked directories are not checked, though
+	sum := sha256.New()
+	for _, c := range changes {
+		sum.Write(c)
+		sum.Write([]byte{0})
+	}
+	for _, p := range paths {
+		if fi, err := os.Lstat(filepath.Join(h.root, p)); err == nil {
+			fmt.Fprintf(sum, "%s\x00%d\x00%d\x00", p, fi.Size(), fi.ModTime().UnixNano())
+		}
+	}
+	s.worktree = hex.EncodeToString(sum.Sum(nil))
+	s.dirty = len(changes) > 0
+
+	return nil
+}
+
+// statusPath returns the path of a `git status --porcelain=v2` change
+// record, or an empty string for an unknown one.
+func statusPath(rec []byte) string {
+	var n int
+	switch rec[0] {
+	case '1':
+		n = 9
+	case '2':
+		n = 10
+	case 'u':
+		n = 11
+	case '?', '!':
+		n = 2
+	default:
+		return ""
+	}
+
+	fields := bytes.SplitN(rec, []byte(" "), n)
+	if len(fields) < n {
+		return ""
+	}
+	return string(fields[n-1])
+}
@@ -0,0 +1,130 @@
+package git
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestWatch(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "file.txt")
+	err := os.WriteFile(file, []byte("v1"), 0644)
+	assert.NoError(t, err)
+	err = g.CommitFiles([]string{file}, "Initial commit")
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := g.Watch(ctx, WatchOptions{
+		Interval: 20 * time.Millisecond,
+		Debounce: 20 * time.Millisecond,
+	})
+	assert.NoError(t, err)
+
+	wait := func(typ WatchEventType) WatchEvent {
+		t.Helper()
+		timeout := time.After(5 * time.Second)
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					t.Fatalf("channel closed waiting for %s", typ)
+				}
+				if e.Type == typ {
+					return e
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for %s", typ)
+			}
+		}
+	}
+
+	err = os.WriteFile(file, []byte("v2"), 0644)
+	assert.NoError(t, err)
+	e := wait(WorktreeDirty)
+	assert.Equal(t, true, e.Dirty)
+
+	err = os.WriteFile(file, []byte("v2, edited again"), 0644)
+	assert.NoError(t, err)
+	e = wait(WorktreeDirty)
+	assert.Equal(t, true, e.Dirty)
+
+	err = g.AddToStaging([]string{file})
+	assert.NoError(t, err)
+	wait(IndexChanged)
+
+	old, err := g.LatestHash()
+	assert.NoError(t, err)
+	err = g.Commit("Second commit")
+	assert.NoError(t, err)
+	e = wait(HeadMoved)
+	assert.Equal(t, old, e.Old)
+
+	err = g.CheckoutNewBranch("feature")
+	assert.NoError(t, err)
+	e = wait(BranchChanged)
+	assert.Equal(t, "main", e.Old)
+	assert.Equal(t, "feature", e.New)
+
+	_, err = g.(*handlerImpl).execute("update-ref", "refs/remotes/origin/main", "HEAD")
+	assert.NoError(t, err)
+	e = wait(RemoteRefUpdated)
+	assert.Equal(t, "refs/remotes/origin/main", e.Ref)
+	assert.Equal(t, "", e.Old)
+
+	err = os.WriteFile(file, []byte("v3"), 0644)
+	assert.NoError(t, err)
+	_, err = g.Stash("Work in progress")
+	assert.NoError(t, err)
+	wait(StashChanged)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchUntraced(t *testing.T) {
+	g, dir := newTestRepo(t, "main")
+	defer os.RemoveAll(dir)
+
+	var mu sync.Mutex
+	var observed []CommandEvent
+	g.AddObserver(func(e CommandEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed = append(observed, e)
+	})
+
+	audit := filepath.Join(dir, "audit.jsonl")
+	err := g.SetAuditFile(audit)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := g.Watch(ctx, WatchOptions{Interval: 10 * time.Millisecond})
+	assert.NoError(t, err)
+
+	mu.Lock()
+	before := len(observed)
+	mu.Unlock()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	for range events {
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, before, len(observed))
+
+	b, _ := os.ReadFile(audit)
+	assert.Equal(t, before, len(splitLines(b)))
+}
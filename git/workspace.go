@@ -0,0 +1,433 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// DefaultWorkspaceConcurrency is the number of repositories processed at
+// the same time when Workspace.Concurrency is not set.
+const DefaultWorkspaceConcurrency = 4
+
+// Workspace defines a set of repositories under a common directory.
+type Workspace struct {
+	Root string
+
+	// Repos are the workspace repositories, sorted by path.
+	Repos []WorkspaceRepo
+
+	// Concurrency bounds the number of repositories processed at the same time.
+	Concurrency int
+}
+
+// WorkspaceRepo defines a repository in a workspace.
+type WorkspaceRepo struct {
+	// Path is relative to the workspace root.
+	Path    string
+	Handler Handler
+}
+
+// WorkspaceResult defines the outcome of an operation on a workspace repository.
+type WorkspaceResult struct {
+	Path    string `json:"path"`
+	Branch  string `json:"branch"`
+	Summary string `json:"summary"`
+	Err     error  `json:"-"`
+}
+
+// WorkspaceResults defines the outcome of an operation on a workspace.
+type WorkspaceResults []WorkspaceResult
+
+// Err returns the per-repository errors, joined, or nil if there are none.
+func (rs WorkspaceResults) Err() error {
+	var errs []error
+	for _, r := range rs {
+		if r.Err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.Path, r.Err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Table writes the results to w as an aligned table.
+func (rs WorkspaceResults) Table(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "REPOSITORY\tBRANCH\tRESULT")
+	for _, r := range rs {
+		result := r.Summary
+		if r.Err != nil {
+			result = "error: " + firstLine(r.Err.Error())
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Path, r.Branch, result)
+	}
+	return tw.Flush()
+}
+
+// WorkspaceManifest defines the repositories expected in a workspace.
+type WorkspaceManifest struct {
+	Repos []ManifestRepo `json:"repos"`
+}
+
+// ManifestRepo defines a repository in a workspace manifest.
+type ManifestRepo struct {
+	// Path is relative to the workspace root.
+	Path string `json:"path"`
+
+	// Remotes maps remote names to URLs. The repository is cloned from
+	// `origin`, if present, or from the first remote in name order.
+	Remotes map[string]string `json:"remotes"`
+
+	// Branch is checked out after cloning, if given.
+	Branch string `json:"branch,omitempty"`
+}
+
+// DiscoverWorkspace returns a workspace with the repositories found
+// under the given root directory, nested ones included.
+func DiscoverWorkspace(root string) (*Workspace, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Workspace{Root: root}
+
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+
+		// NOTE: .git is a file for worktrees and submodules
+		if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+			return nil
+		}
+
+		g, err := NewHandler(path)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		w.Repos = append(w.Repos, WorkspaceRepo{Path: rel, Handler: g})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// LoadWorkspaceManifest reads a workspace manifest from the given JSON file.
+func LoadWorkspaceManifest(file string) (*WorkspaceManifest, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &WorkspaceManifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("invalid workspace manifest %s: %w", file, err)
+	}
+
+	for i, r := range m.Repos {
+		if r.Path == "" || len(r.Remotes) == 0 {
+			return nil, fmt.Errorf("invalid workspace manifest %s: repo %d requires a path and a remote", file, i+1)
+		}
+	}
+
+	return m, nil
+}
+
+// Clone clones the manifest's repositories missing under the given root
+// directory, and returns the resulting workspace. The given EnvOptions, if
+// any, are set on every repository's handler before cloning.
+func (m *WorkspaceManifest) Clone(ctx context.Context, root string, concurrency int, env ...EnvOptions) (*Workspace, WorkspaceResults, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !HasGit() {
+		return nil, nil, fmt.Errorf("Unable to find the git command")
+	}
+
+	w := &Workspace{Root: root, Concurrency: concurrency}
+	repos := map[string]ManifestRepo{}
+	for _, r := range m.Repos {
+		// NOTE: the root is set explicitly, since the repository may not
+		// exist yet, but its parent directory may belong to another one
+		g := &handlerImpl{
+			root: filepath.Join(root, r.Path),
+			log:  slog.Default().WithGroup("git"),
+		}
+		if len(env) > 0 {
+			if err := g.SetEnvOptions(env[0]); err != nil {
+				return nil, nil, err
+			}
+		}
+		w.Repos = append(w.Repos, WorkspaceRepo{Path: filepath.Clean(r.Path), Handler: g})
+		repos[filepath.Clean(r.Path)] = r
+	}
+	w.sortRepos()
+
+	clone := func(path string, g Handler) (string, error) {
+		r := repos[path]
+
+		if _, err := os.Stat(filepath.Join(root, path, ".git")); err == nil {
+			return "present", nil
+		}
+
+		names := make([]string, 0, len(r.Remotes))
+		for name := range r.Remotes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		origin := names[0]
+		if _, ok := r.Remotes["origin"]; ok {
+			origin = "origin"
+		}
+
+		if err := g.Clone(r.Remotes[origin], CloneOptions{Branch: r.Branch, Origin: origin}); err != nil {
+			return "", err
+		}
+
+		for _, name := range names {
+			if name == origin {
+				continue
+			}
+			if err := g.SetRemote(name, r.Remotes[name]); err != nil {
+				return "", err
+			}
+		}
+
+		return "cloned", nil
+	}
+
+	// NOTE: nested repositories are cloned only after the ones containing them
+	results := make(WorkspaceResults, len(w.Repos))
+	for _, level := range w.nestingLevels() {
+		sub := &Workspace{Root: root, Concurrency: concurrency}
+		for _, i := range level {
+			sub.Repos = append(sub.Repos, w.Repos[i])
+		}
+
+		for j, r := range sub.Run(ctx, clone) {
+			results[level[j]] = r
+		}
+	}
+
+	return w, results, nil
+}
+
+// Manifest returns a manifest describing the workspace's repositories.
+func (w *Workspace) Manifest() (*WorkspaceManifest, error) {
+	m := &WorkspaceManifest{}
+	for _, r := range w.Repos {
+		remotes, err := r.Handler.Remotes()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Path, err)
+		}
+
+		branch, err := r.Handler.Branch()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", r.Path, err)
+		}
+
+		m.Repos = append(m.Repos, ManifestRepo{Path: r.Path, Remotes: remotes, Branch: branch})
+	}
+	return m, nil
+}
+
+// Run runs fn on every repository in the workspace, with bounded
+// concurrency, and returns the results in repository order. Repositories
+// not yet processed when ctx is done report ctx's error.
+func (w *Workspace) Run(ctx context.Context, fn func(path string, g Handler) (summary string, err error)) WorkspaceResults {
+	concurrency := w.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultWorkspaceConcurrency
+	}
+
+	results := make(WorkspaceResults, len(w.Repos))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+
+	for i, r := range w.Repos {
+		results[i].Path = r.Path
+
+		select {
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+
+		wg.Add(1)
+		go func(i int, r WorkspaceRepo) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			if err := ctx.Err(); err != nil {
+				results[i].Err = err
+				return
+			}
+
+			results[i].Summary, results[i].Err = fn(r.Path, r.Handler)
+			results[i].Branch, _ = r.Handler.Branch()
+		}(i, r)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// BranchReport reports, for every repository, how its current branch
+// compares to its upstream.
+func (w *Workspace) BranchReport(ctx context.Context) WorkspaceResults {
+	return w.Run(ctx, func(_ string, g Handler) (string, error) {
+		branch, err := g.Branch()
+		if err != nil {
+			return "", err
+		}
+		if branch == "" {
+			return "detached", nil
+		}
+
+		if _, err := g.ConfigAt(ConfigScopeAny, "branch."+branch+".remote"); err != nil {
+			if errors.Is(err, ErrConfigKeyNotFound) {
+				return "no upstream", nil
+			}
+			return "", err
+		}
+
+		ahead, behind, err := g.AheadBehind("HEAD", "@{upstream}")
+		if err != nil {
+			return "", err
+		}
+
+		if ahead == 0 && behind == 0 {
+			return "up to date", nil
+		}
+		return fmt.Sprintf("ahead %d, behind %d", ahead, behind), nil
+	})
+}
+
+// Fetch fetches the given remote, or the default one, in every repository.
+func (w *Workspace) Fetch(ctx context.Context, remote string) WorkspaceResults {
+	return w.Run(ctx, func(_ string, g Handler) (string, error) {
+		if err := g.Fetch(remote); err != nil {
+			return "", err
+		}
+		return "fetched", nil
+	})
+}
+
+// Pull pulls the current branch from the given remote in every repository.
+// If no remote is given, the branch's upstream remote is used. When pulling
+// from the upstream remote, the upstream branch is pulled, even if it is
+// named differently.
+func (w *Workspace) Pull(ctx context.Context, remote string) WorkspaceResults {
+	return w.Run(ctx, func(_ string, g Handler) (string, error) {
+		branch, err := g.Branch()
+		if err != nil {
+			return "", err
+		}
+		if branch == "" {
+			return "", fmt.Errorf("not on a branch")
+		}
+
+		upstreamRemote, _ := g.ConfigAt(ConfigScopeAny, "branch."+branch+".remote")
+
+		from := remote
+		if from == "" {
+			if upstreamRemote == "" {
+				return "", fmt.Errorf("branch %q has no upstream remote", branch)
+			}
+			from = upstreamRemote
+		}
+
+		pull := branch
+		if from == upstreamRemote {
+			merge, _ := g.ConfigAt(ConfigScopeAny, "branch."+branch+".merge")
+			if merge != "" {
+				pull = strings.TrimPrefix(merge, "refs/heads/")
+			}
+		}
+
+		if err := g.Pull(from, pull); err != nil {
+			return "", err
+		}
+		return "pulled", nil
+	})
+}
+
+// Status reports the working tree status of every repository.
+func (w *Workspace) Status(ctx context.Context) WorkspaceResults {
+	return w.Run(ctx, func(_ string, g Handler) (string, error) {
+		staged, unstaged, untracked, err := g.Status()
+		if err != nil {
+			return "", err
+		}
+
+		if len(staged)+len(unstaged)+len(untracked) == 0 {
+			return "clean", nil
+		}
+		return fmt.Sprintf("%d staged, %d unstaged, %d untracked",
+			len(staged), len(unstaged), len(untracked)), nil
+	})
+}
+
+// nestingLevels groups the indexes of the repositories, sorted by path,
+// so that each group only contains repositories nested in previous ones.
+func (w *Workspace) nestingLevels() (levels [][]int) {
+	depth := make([]int, len(w.Repos))
+	for i, r := range w.Repos {
+		for j := range i {
+			prefix := w.Repos[j].Path + string(filepath.Separator)
+			if strings.HasPrefix(r.Path, prefix) && depth[j]+1 > depth[i] {
+				depth[i] = depth[j] + 1
+			}
+		}
+
+		if depth[i] == len(levels) {
+			levels = append(levels, nil)
+		}
+		levels[depth[i]] = append(levels[depth[i]], i)
+	}
+	return
+}
+
+func (w *Workspace) sortRepos() {
+	slices.SortFunc(w.Repos, func(a, b WorkspaceRepo) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+	return line
+}
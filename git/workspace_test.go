@@ -0,0 +1,168 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jwmwalrus/bnp/tests"
+	"github.com/jwmwalrus/bnp/tests/assert"
+)
+
+func TestWorkspace(t *testing.T) {
+	upstream, udir := newTestRepo(t, "main")
+	defer os.RemoveAll(udir)
+
+	err := os.WriteFile(filepath.Join(udir, "file.txt"), []byte("v1"), 0644)
+	assert.NoError(t, err)
+	err = upstream.CommitFiles([]string{"file.txt"}, "Initial commit")
+	assert.NoError(t, err)
+
+	root := tests.NewTempDir(t)
+	defer os.RemoveAll(root)
+
+	t.Run("Manifest clone", func(t *testing.T) {
+		m := WorkspaceManifest{Repos: []ManifestRepo{
+			{Path: "b", Remotes: map[string]string{"upstream": udir}},
+			{Path: "a", Remotes: map[string]string{"origin": udir, "mirror": udir}, Branch: "main"},
+			{Path: "a/nested", Remotes: map[string]string{"origin": udir}},
+		}}
+		b, err := json.Marshal(m)
+		assert.NoError(t, err)
+
+		file := filepath.Join(root, "workspace.json")
+		err = os.WriteFile(file, b, 0644)
+		assert.NoError(t, err)
+
+		loaded, err := LoadWorkspaceManifest(file)
+		assert.NoError(t, err)
+
+		w, results, err := loaded.Clone(context.Background(), root, 2)
+		assert.NoError(t, err)
+		assert.NoError(t, results.Err())
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, "a", results[0].Path)
+		assert.Equal(t, "cloned", results[0].Summary)
+		assert.Equal(t, "main", results[0].Branch)
+		assert.Equal(t, 3, len(w.Repos))
+
+		remotes, err := w.Repos[0].Handler.Remotes()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, len(remotes))
+
+		remotes, err = w.Repos[2].Handler.Remotes()
+		assert.NoError(t, err)
+		assert.Equal(t, udir, remotes["upstream"])
+
+		_, results, err = loaded.Clone(context.Background(), root, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, "present", results[1].Summary)
+	})
+
+	t.Run("Manifest clone with environment options", func(t *testing.T) {
+		root := tests.NewTempDir(t)
+		defer os.RemoveAll(root)
+
+		m := WorkspaceManifest{Repos: []ManifestRepo{
+			{Path: "c", Remotes: map[string]string{"origin": "example:upstream"}},
+		}}
+
+		_, _, err := m.Clone(context.Background(), root, 1, EnvOptions{Env: []string{"=invalid"}})
+		assert.Error(t, err)
+
+		w, results, err := m.Clone(context.Background(), root, 1, EnvOptions{Env: []string{
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=url." + udir + ".insteadOf",
+			"GIT_CONFIG_VALUE_0=example:upstream",
+		}})
+		assert.NoError(t, err)
+		assert.NoError(t, results.Err())
+		assert.Equal(t, "cloned", results[0].Summary)
+
+		_, err = os.Stat(filepath.Join(w.Root, "c", "file.txt"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Discover and report", func(t *testing.T) {
+		w, err := DiscoverWorkspace(root)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(w.Repos))
+		assert.Equal(t, filepath.Join("a", "nested"), w.Repos[1].Path)
+
+		err = os.WriteFile(filepath.Join(root, "b", "new.txt"), []byte("new"), 0644)
+		assert.NoError(t, err)
+
+		results := w.Status(context.Background())
+		assert.NoError(t, results.Err())
+		assert.Equal(t, "clean", results[1].Summary)
+		assert.Equal(t, "0 staged, 0 unstaged, 1 untracked", results[2].Summary)
+
+		err = os.WriteFile(filepath.Join(udir, "file.txt"), []byte("v2"), 0644)
+		assert.NoError(t, err)
+		err = upstream.CommitFiles([]string{"file.txt"}, "Second commit")
+		assert.NoError(t, err)
+
+		results = w.Fetch(context.Background(), "")
+		assert.NoError(t, results.Err())
+
+		results = w.BranchReport(context.Background())
+		assert.NoError(t, results.Err())
+		assert.Equal(t, "ahead 0, behind 1", results[0].Summary)
+
+		results = w.Pull(context.Background(), "")
+		assert.NoError(t, results.Err())
+
+		results = w.BranchReport(context.Background())
+		assert.Equal(t, "up to date", results[0].Summary)
+
+		buf := &bytes.Buffer{}
+		err = results.Table(buf)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, len(strings.Split(strings.TrimSpace(buf.String()), "\n")))
+
+		m, err := w.Manifest()
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(m.Repos))
+		assert.Equal(t, "main", m.Repos[0].Branch)
+	})
+
+	t.Run("Pull renamed upstream", func(t *testing.T) {
+		w, err := DiscoverWorkspace(root)
+		assert.NoError(t, err)
+
+		g := w.Repos[0].Handler
+		err = g.CheckoutNewBranch("local")
+		assert.NoError(t, err)
+		err = g.SetUpstreamBranchTo("origin", "main")
+		assert.NoError(t, err)
+
+		err = os.WriteFile(filepath.Join(udir, "file.txt"), []byte("v3"), 0644)
+		assert.NoError(t, err)
+		err = upstream.CommitFiles([]string{"file.txt"}, "Third commit")
+		assert.NoError(t, err)
+
+		results := w.Pull(context.Background(), "")
+		assert.NoError(t, results.Err())
+
+		content, err := os.ReadFile(filepath.Join(root, "a", "file.txt"))
+		assert.NoError(t, err)
+		assert.Equal(t, "v3", string(content))
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		w, err := DiscoverWorkspace(root)
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		results := w.Status(ctx)
+		assert.Equal(t, 3, len(results))
+		assert.Equal(t, true, errors.Is(results.Err(), context.Canceled))
+	})
+}